@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sagernet/serenity/common/cachefile"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/spf13/cobra"
+)
+
+var commandSubscription = &cobra.Command{
+	Use:   "subscription",
+	Short: "Manage cached subscription versions, serenity must not be running",
+}
+
+var commandSubscriptionHistory = &cobra.Command{
+	Use:   "history <name>",
+	Short: "List stored versions of a subscription",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := subscriptionHistory(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var commandSubscriptionRollback = &cobra.Command{
+	Use:   "rollback <name> <version>",
+	Short: "Use a stored version of a subscription until the provider serves different content",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := subscriptionRollback(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var commandSubscriptionPin = &cobra.Command{
+	Use:   "pin <name> <version>",
+	Short: "Use a stored version of a subscription and stop updating it until unpinned",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := subscriptionPin(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var commandSubscriptionUnpin = &cobra.Command{
+	Use:   "unpin <name>",
+	Short: "Resume updating a pinned subscription",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := subscriptionUnpin(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandSubscription.AddCommand(commandSubscriptionHistory)
+	commandSubscription.AddCommand(commandSubscriptionRollback)
+	commandSubscription.AddCommand(commandSubscriptionPin)
+	commandSubscription.AddCommand(commandSubscriptionUnpin)
+	mainCommand.AddCommand(commandSubscription)
+}
+
+func openCacheFile() (*cachefile.CacheFile, error) {
+	options, err := readConfigAndMerge()
+	if err != nil {
+		return nil, err
+	}
+	cacheFilePath := options.CacheFile
+	if cacheFilePath == "" {
+		cacheFilePath = "cache.db"
+	}
+	cacheFile := cachefile.New(globalCtx, cacheFilePath)
+	err = cacheFile.Start()
+	if err != nil {
+		return nil, E.Cause(err, "open cache file")
+	}
+	return cacheFile, nil
+}
+
+func parseVersion(version string) (uint64, error) {
+	versionNumber, err := strconv.ParseUint(version, 10, 64)
+	if err != nil || versionNumber == 0 {
+		return 0, E.New("invalid version: ", version)
+	}
+	return versionNumber, nil
+}
+
+func subscriptionHistory(name string) error {
+	cacheFile, err := openCacheFile()
+	if err != nil {
+		return err
+	}
+	defer cacheFile.Close()
+	entries, err := cacheFile.ListSubscriptionHistory(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return E.New("no stored versions of subscription ", name)
+	}
+	pinnedVersion := cacheFile.LoadSubscriptionPin(name)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writer.Write([]byte("VERSION\tUPDATED\tSERVERS\tENDPOINTS\tHASH\t\n"))
+	for _, entry := range entries {
+		var pinned string
+		if entry.Version == pinnedVersion {
+			pinned = "pinned"
+		}
+		var contentHash string
+		if len(entry.ContentHash) >= 6 {
+			contentHash = hex.EncodeToString(entry.ContentHash[:6])
+		}
+		writer.Write([]byte(F.ToString(entry.Version, "\t", entry.LastUpdated.Format(time.DateTime), "\t", len(entry.Content), "\t", len(entry.Endpoints), "\t", contentHash, "\t", pinned, "\n")))
+	}
+	return writer.Flush()
+}
+
+func subscriptionRollback(name string, version string) error {
+	versionNumber, err := parseVersion(version)
+	if err != nil {
+		return err
+	}
+	cacheFile, err := openCacheFile()
+	if err != nil {
+		return err
+	}
+	defer cacheFile.Close()
+	err = cacheFile.RollbackSubscription(name, versionNumber)
+	if err != nil {
+		return err
+	}
+	if pinnedVersion := cacheFile.LoadSubscriptionPin(name); pinnedVersion != 0 {
+		log.Warn("subscription ", name, " is still pinned to version ", pinnedVersion)
+	}
+	return nil
+}
+
+func subscriptionPin(name string, version string) error {
+	versionNumber, err := parseVersion(version)
+	if err != nil {
+		return err
+	}
+	cacheFile, err := openCacheFile()
+	if err != nil {
+		return err
+	}
+	defer cacheFile.Close()
+	return cacheFile.StoreSubscriptionPin(name, versionNumber)
+}
+
+func subscriptionUnpin(name string) error {
+	cacheFile, err := openCacheFile()
+	if err != nil {
+		return err
+	}
+	defer cacheFile.Close()
+	return cacheFile.DeleteSubscriptionPin(name)
+}
@@ -1,6 +1,7 @@
 package cachefile
 
 import (
+	"context"
 	"errors"
 	"os"
 	"time"
@@ -12,20 +13,26 @@ import (
 )
 
 var (
-	bucketSubscription = []byte("subscription")
+	bucketSubscription        = []byte("subscription")
+	bucketSubscriptionHistory = []byte("subscription_history")
+	bucketSubscriptionPin     = []byte("subscription_pin")
 
 	bucketNameList = []string{
 		string(bucketSubscription),
+		string(bucketSubscriptionHistory),
+		string(bucketSubscriptionPin),
 	}
 )
 
 type CacheFile struct {
+	ctx  context.Context
 	path string
 	DB   *bbolt.DB
 }
 
-func New(path string) *CacheFile {
+func New(ctx context.Context, path string) *CacheFile {
 	return &CacheFile{
+		ctx:  ctx,
 		path: path,
 	}
 }
@@ -91,7 +98,7 @@ func (c *CacheFile) LoadSubscription(name string) *Subscription {
 		if data == nil {
 			return nil
 		}
-		return subscription.UnmarshalBinary(data)
+		return subscription.UnmarshalBinaryContext(c.ctx, data)
 	})
 	if err != nil {
 		return nil
@@ -0,0 +1,180 @@
+package cachefile
+
+import (
+	"encoding/binary"
+
+	"github.com/sagernet/bbolt"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// HistoryEntry is a previously fetched version of a subscription.
+type HistoryEntry struct {
+	Version uint64
+	*Subscription
+}
+
+func historyKey(version uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, version)
+}
+
+// StoreSubscriptionHistory appends a fetched version of the subscription and removes
+// the oldest versions beyond limit. It returns the new version number.
+func (c *CacheFile) StoreSubscriptionHistory(name string, subscription *Subscription, limit int) (uint64, error) {
+	data, err := subscription.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	var version uint64
+	err = c.DB.Batch(func(tx *bbolt.Tx) error {
+		historyBucket, err := tx.CreateBucketIfNotExists(bucketSubscriptionHistory)
+		if err != nil {
+			return err
+		}
+		bucket, err := historyBucket.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		version, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		err = bucket.Put(historyKey(version), data)
+		if err != nil {
+			return err
+		}
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			keys = append(keys, key)
+		}
+		for len(keys) > limit {
+			err = bucket.Delete(keys[0])
+			if err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// ListSubscriptionHistory returns the stored versions of the subscription, oldest first.
+func (c *CacheFile) ListSubscriptionHistory(name string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	err := c.DB.View(func(tx *bbolt.Tx) error {
+		historyBucket := tx.Bucket(bucketSubscriptionHistory)
+		if historyBucket == nil {
+			return nil
+		}
+		bucket := historyBucket.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var subscription Subscription
+			err := subscription.UnmarshalBinaryContext(c.ctx, value)
+			if err != nil {
+				return E.Cause(err, "load version ", binary.BigEndian.Uint64(key))
+			}
+			entries = append(entries, HistoryEntry{binary.BigEndian.Uint64(key), &subscription})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (c *CacheFile) LoadSubscriptionHistory(name string, version uint64) *Subscription {
+	var subscription *Subscription
+	err := c.DB.View(func(tx *bbolt.Tx) error {
+		historyBucket := tx.Bucket(bucketSubscriptionHistory)
+		if historyBucket == nil {
+			return nil
+		}
+		bucket := historyBucket.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get(historyKey(version))
+		if data == nil {
+			return nil
+		}
+		subscription = new(Subscription)
+		return subscription.UnmarshalBinaryContext(c.ctx, data)
+	})
+	if err != nil {
+		return nil
+	}
+	return subscription
+}
+
+// RollbackSubscription replaces the content of the subscription with a stored version.
+// The fetch state of the current record is kept and the replaced content is recorded,
+// so the rolled back servers are used until the provider serves different content.
+func (c *CacheFile) RollbackSubscription(name string, version uint64) error {
+	historySubscription := c.LoadSubscriptionHistory(name, version)
+	if historySubscription == nil {
+		return E.New("version ", version, " of subscription ", name, " not found")
+	}
+	subscription := c.LoadSubscription(name)
+	if subscription == nil {
+		subscription = historySubscription
+	} else {
+		// the provider's content is recorded by the first of consecutive rollbacks
+		if subscription.ReplacedHash == nil {
+			subscription.ReplacedHash = subscription.ContentHash
+		}
+		subscription.Content = historySubscription.Content
+		subscription.Endpoints = historySubscription.Endpoints
+		subscription.ContentHash = historySubscription.ContentHash
+		subscription.RawContent = historySubscription.RawContent
+		subscription.ParserVersion = historySubscription.ParserVersion
+	}
+	return c.StoreSubscription(name, subscription)
+}
+
+// LoadSubscriptionPin returns the version the subscription is pinned to, or 0 if it is not pinned.
+func (c *CacheFile) LoadSubscriptionPin(name string) uint64 {
+	var version uint64
+	c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketSubscriptionPin)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(name))
+		if len(data) == 8 {
+			version = binary.BigEndian.Uint64(data)
+		}
+		return nil
+	})
+	return version
+}
+
+func (c *CacheFile) StoreSubscriptionPin(name string, version uint64) error {
+	if c.LoadSubscriptionHistory(name, version) == nil {
+		return E.New("version ", version, " of subscription ", name, " not found")
+	}
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketSubscriptionPin)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), historyKey(version))
+	})
+}
+
+func (c *CacheFile) DeleteSubscriptionPin(name string) error {
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketSubscriptionPin)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
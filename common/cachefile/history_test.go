@@ -0,0 +1,67 @@
+package cachefile
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionHistory(t *testing.T) {
+	t.Parallel()
+	ctx := box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+	cacheFile := New(ctx, filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, cacheFile.Start())
+	defer cacheFile.Close()
+	newSubscription := func(tag string) *Subscription {
+		return &Subscription{
+			Content: []option.Outbound{{
+				Type:    C.TypeDirect,
+				Tag:     tag,
+				Options: &option.DirectOutboundOptions{},
+			}},
+			LastUpdated: time.Unix(1700000000, 0),
+			LastEtag:    tag,
+			ContentHash: []byte(tag),
+		}
+	}
+	for _, tag := range []string{"a", "b", "c", "d"} {
+		_, err := cacheFile.StoreSubscriptionHistory("test", newSubscription(tag), 3)
+		require.NoError(t, err)
+	}
+	entries, err := cacheFile.ListSubscriptionHistory("test")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for index, tag := range []string{"b", "c", "d"} {
+		require.Equal(t, uint64(index+2), entries[index].Version)
+		require.Equal(t, tag, entries[index].Content[0].Tag)
+	}
+	require.Nil(t, cacheFile.LoadSubscriptionHistory("test", 1))
+
+	require.NoError(t, cacheFile.StoreSubscription("test", newSubscription("broken")))
+	require.NoError(t, cacheFile.RollbackSubscription("test", 3))
+	current := cacheFile.LoadSubscription("test")
+	require.Equal(t, "c", current.Content[0].Tag)
+	require.Equal(t, "broken", current.LastEtag)
+	require.Equal(t, []byte("c"), current.ContentHash)
+	require.Equal(t, []byte("broken"), current.ReplacedHash)
+	require.NoError(t, cacheFile.RollbackSubscription("test", 2))
+	current = cacheFile.LoadSubscription("test")
+	require.Equal(t, []byte("b"), current.ContentHash)
+	require.Equal(t, []byte("broken"), current.ReplacedHash)
+	require.Error(t, cacheFile.RollbackSubscription("test", 1))
+
+	require.Zero(t, cacheFile.LoadSubscriptionPin("test"))
+	require.Error(t, cacheFile.StoreSubscriptionPin("test", 1))
+	require.NoError(t, cacheFile.StoreSubscriptionPin("test", 2))
+	require.Equal(t, uint64(2), cacheFile.LoadSubscriptionPin("test"))
+	require.NoError(t, cacheFile.DeleteSubscriptionPin("test"))
+	require.Zero(t, cacheFile.LoadSubscriptionPin("test"))
+}
@@ -2,23 +2,42 @@ package cachefile
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/binary"
+	"io"
 	"time"
 
+	"github.com/sagernet/serenity/common/userinfo"
 	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/varbin"
 )
 
+const subscriptionVersion = 6
+
 type Subscription struct {
 	Content     []option.Outbound
+	Endpoints   []option.Endpoint
 	LastUpdated time.Time
 	LastEtag    string
+	UserInfo    *userinfo.Info
+	// LastModified is the Last-Modified header of the last fetch
+	LastModified string
+	ContentHash  []byte
+	// RawContent is the fetched body that Content was parsed from, stored compressed
+	RawContent []byte
+	// ParserVersion is the parser.Version that Content was parsed with
+	ParserVersion uint32
+	// ReplacedHash is the ContentHash of the content replaced by a rollback,
+	// which is ignored when fetched again so that the rolled back content is kept
+	ReplacedHash []byte
 }
 
 func (c *Subscription) MarshalBinary() ([]byte, error) {
 	var buffer bytes.Buffer
-	buffer.WriteByte(1)
+	buffer.WriteByte(subscriptionVersion)
 	content, err := json.Marshal(c.Content)
 	if err != nil {
 		return nil, err
@@ -39,26 +58,89 @@ func (c *Subscription) MarshalBinary() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	endpoints, err := json.Marshal(c.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	_, err = varbin.WriteUvarint(&buffer, uint64(len(endpoints)))
+	if err != nil {
+		return nil, err
+	}
+	_, err = buffer.Write(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserInfo == nil {
+		buffer.WriteByte(0)
+	} else {
+		buffer.WriteByte(1)
+		var expire int64
+		if !c.UserInfo.Expire.IsZero() {
+			expire = c.UserInfo.Expire.Unix()
+		}
+		for _, value := range []any{c.UserInfo.Upload, c.UserInfo.Download, c.UserInfo.Total, expire} {
+			err = binary.Write(&buffer, binary.BigEndian, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, c.LastModified)
+	if err != nil {
+		return nil, err
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, c.ContentHash)
+	if err != nil {
+		return nil, err
+	}
+	var rawContent []byte
+	if len(c.RawContent) > 0 {
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		_, err = gzipWriter.Write(c.RawContent)
+		if err != nil {
+			return nil, err
+		}
+		err = gzipWriter.Close()
+		if err != nil {
+			return nil, err
+		}
+		rawContent = compressed.Bytes()
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, rawContent)
+	if err != nil {
+		return nil, err
+	}
+	_, err = varbin.WriteUvarint(&buffer, uint64(c.ParserVersion))
+	if err != nil {
+		return nil, err
+	}
+	err = varbin.Write(&buffer, binary.BigEndian, c.ReplacedHash)
+	if err != nil {
+		return nil, err
+	}
 	return buffer.Bytes(), nil
 }
 
-func (c *Subscription) UnmarshalBinary(data []byte) error {
+func (c *Subscription) UnmarshalBinaryContext(ctx context.Context, data []byte) error {
 	reader := bytes.NewReader(data)
 	version, err := reader.ReadByte()
 	if err != nil {
 		return err
 	}
-	_ = version
+	if version == 0 || version > subscriptionVersion {
+		return E.New("unknown subscription cache version: ", version)
+	}
 	contentLength, err := binary.ReadUvarint(reader)
 	if err != nil {
 		return err
 	}
 	content := make([]byte, contentLength)
-	_, err = reader.Read(content)
+	_, err = io.ReadFull(reader, content)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(content, &c.Content)
+	err = json.UnmarshalContext(ctx, content, &c.Content)
 	if err != nil {
 		return err
 	}
@@ -72,5 +154,85 @@ func (c *Subscription) UnmarshalBinary(data []byte) error {
 	if err != nil {
 		return err
 	}
+	if version < 2 {
+		return nil
+	}
+	endpointsLength, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	endpoints := make([]byte, endpointsLength)
+	_, err = io.ReadFull(reader, endpoints)
+	if err != nil {
+		return err
+	}
+	err = json.UnmarshalContext(ctx, endpoints, &c.Endpoints)
+	if err != nil {
+		return err
+	}
+	if version < 3 {
+		return nil
+	}
+	hasUserInfo, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if hasUserInfo != 0 {
+		var (
+			info   userinfo.Info
+			expire int64
+		)
+		for _, value := range []any{&info.Upload, &info.Download, &info.Total, &expire} {
+			err = binary.Read(reader, binary.BigEndian, value)
+			if err != nil {
+				return err
+			}
+		}
+		if expire > 0 {
+			info.Expire = time.Unix(expire, 0)
+		}
+		c.UserInfo = &info
+	}
+	if version < 4 {
+		return nil
+	}
+	err = varbin.Read(reader, binary.BigEndian, &c.LastModified)
+	if err != nil {
+		return err
+	}
+	err = varbin.Read(reader, binary.BigEndian, &c.ContentHash)
+	if err != nil {
+		return err
+	}
+	if version < 5 {
+		return nil
+	}
+	var rawContent []byte
+	err = varbin.Read(reader, binary.BigEndian, &rawContent)
+	if err != nil {
+		return err
+	}
+	if len(rawContent) > 0 {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(rawContent))
+		if err != nil {
+			return E.Cause(err, "decompress raw content")
+		}
+		c.RawContent, err = io.ReadAll(gzipReader)
+		if err != nil {
+			return E.Cause(err, "decompress raw content")
+		}
+	}
+	parserVersion, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	c.ParserVersion = uint32(parserVersion)
+	if version < 6 {
+		return nil
+	}
+	err = varbin.Read(reader, binary.BigEndian, &c.ReplacedHash)
+	if err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,49 @@
+package cachefile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagernet/serenity/common/userinfo"
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionBinary(t *testing.T) {
+	t.Parallel()
+	ctx := box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+	subscription := &Subscription{
+		Content: []option.Outbound{{
+			Type:    C.TypeDirect,
+			Tag:     "direct",
+			Options: &option.DirectOutboundOptions{},
+		}},
+		LastUpdated:   time.Unix(1700000000, 0),
+		LastEtag:      "etag",
+		UserInfo:      &userinfo.Info{Upload: 1, Download: 2, Total: 3, Expire: time.Unix(1800000000, 0)},
+		LastModified:  "Tue, 14 Nov 2023 22:13:20 GMT",
+		ContentHash:   []byte{1, 2, 3},
+		RawContent:    []byte("ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example\n"),
+		ParserVersion: 7,
+	}
+	data, err := subscription.MarshalBinary()
+	require.NoError(t, err)
+	var loaded Subscription
+	require.NoError(t, loaded.UnmarshalBinaryContext(ctx, data))
+	require.Equal(t, "direct", loaded.Content[0].Tag)
+	require.Equal(t, subscription.LastUpdated, loaded.LastUpdated)
+	require.Equal(t, subscription.LastEtag, loaded.LastEtag)
+	require.Equal(t, subscription.UserInfo, loaded.UserInfo)
+	require.Equal(t, subscription.LastModified, loaded.LastModified)
+	require.Equal(t, subscription.ContentHash, loaded.ContentHash)
+	require.Equal(t, subscription.RawContent, loaded.RawContent)
+	require.Equal(t, subscription.ParserVersion, loaded.ParserVersion)
+
+	data[0] = subscriptionVersion + 1
+	require.ErrorContains(t, new(Subscription).UnmarshalBinaryContext(ctx, data), "unknown subscription cache version")
+}
@@ -0,0 +1,74 @@
+package geoip
+
+import (
+	"net/netip"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	databaseTypeSingGeoIP = "sing-geoip"
+	// records checked for a country before a database is rejected
+	maxCheckedRecords = 1000
+)
+
+// Reader looks up country codes in a sing-geoip database or a MaxMind compatible database
+// with country records, such as the Country, City and Enterprise databases.
+type Reader struct {
+	reader    *maxminddb.Reader
+	singGeoIP bool
+}
+
+func Open(path string) (*Reader, error) {
+	database, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	databaseType := database.Metadata.DatabaseType
+	if databaseType != databaseTypeSingGeoIP && !hasCountry(database) {
+		database.Close()
+		return nil, E.New("unsupported database type: ", databaseType, ": records have no country.iso_code")
+	}
+	return &Reader{database, databaseType == databaseTypeSingGeoIP}, nil
+}
+
+func hasCountry(database *maxminddb.Reader) bool {
+	networks := database.Networks(maxminddb.SkipAliasedNetworks)
+	for i := 0; i < maxCheckedRecords && networks.Next(); i++ {
+		var record countryRecord
+		_, err := networks.Network(&record)
+		if err != nil {
+			return false
+		}
+		if record.Country.ISOCode != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Lookup returns the upper case ISO 3166-1 country code of the address, or an empty string if unknown.
+func (r *Reader) Lookup(addr netip.Addr) string {
+	var code string
+	if r.singGeoIP {
+		_ = r.reader.Lookup(addr.AsSlice(), &code)
+	} else {
+		var record countryRecord
+		_ = r.reader.Lookup(addr.AsSlice(), &record)
+		code = record.Country.ISOCode
+	}
+	return strings.ToUpper(code)
+}
+
+func (r *Reader) Close() error {
+	return r.reader.Close()
+}
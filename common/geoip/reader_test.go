@@ -0,0 +1,29 @@
+package geoip
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The test databases map 0.0.0.0/1 to the United States and leave 128.0.0.0/1 empty.
+func TestReader(t *testing.T) {
+	t.Parallel()
+	for _, path := range []string{
+		"testdata/sing-geoip.mmdb",
+		"testdata/country.mmdb",
+		"testdata/city.mmdb",
+	} {
+		reader, err := Open(path)
+		require.NoError(t, err, path)
+		require.Equal(t, "US", reader.Lookup(netip.MustParseAddr("1.1.1.1")), path)
+		require.Empty(t, reader.Lookup(netip.MustParseAddr("203.0.113.1")), path)
+		require.Empty(t, reader.Lookup(netip.MustParseAddr("2001:db8::1")), path)
+		require.NoError(t, reader.Close())
+	}
+	_, err := Open("testdata/asn.mmdb")
+	require.ErrorContains(t, err, "unsupported database type: GeoLite2-ASN: records have no country.iso_code")
+	_, err = Open("testdata/missing.mmdb")
+	require.Error(t, err)
+}
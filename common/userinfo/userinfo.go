@@ -0,0 +1,110 @@
+package userinfo
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const HeaderName = "Subscription-Userinfo"
+
+// Info is the traffic and expiry data of a `Subscription-Userinfo` header.
+// A zero Total means unlimited traffic and a zero Expire means never expires.
+type Info struct {
+	Upload   uint64
+	Download uint64
+	Total    uint64
+	Expire   time.Time
+}
+
+// Parse parses a header value such as `upload=1; download=2; total=3; expire=1700000000`.
+// Unknown keys are ignored.
+func Parse(header string) (*Info, error) {
+	var info Info
+	for _, field := range strings.Split(header, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return nil, E.New("invalid field: ", field)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "upload", "download", "total", "expire":
+		default:
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		// some providers send floats such as `1.073741824e+09`
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < 0 {
+			return nil, E.New("invalid ", key, ": ", value)
+		}
+		switch key {
+		case "upload":
+			info.Upload = uint64(number)
+		case "download":
+			info.Download = uint64(number)
+		case "total":
+			info.Total = uint64(number)
+		case "expire":
+			if number > 0 {
+				info.Expire = time.Unix(int64(number), 0)
+			}
+		}
+	}
+	return &info, nil
+}
+
+// String formats the info as a header value.
+func (i *Info) String() string {
+	var builder strings.Builder
+	builder.WriteString("upload=")
+	builder.WriteString(strconv.FormatUint(i.Upload, 10))
+	builder.WriteString("; download=")
+	builder.WriteString(strconv.FormatUint(i.Download, 10))
+	builder.WriteString("; total=")
+	builder.WriteString(strconv.FormatUint(i.Total, 10))
+	if !i.Expire.IsZero() {
+		builder.WriteString("; expire=")
+		builder.WriteString(strconv.FormatInt(i.Expire.Unix(), 10))
+	}
+	return builder.String()
+}
+
+// Merge sums the traffic of all infos and keeps the earliest expiry.
+// The total is unlimited if any info is unlimited. It returns nil if no info is present.
+func Merge(infos ...*Info) *Info {
+	var (
+		merged    *Info
+		unlimited bool
+	)
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		if merged == nil {
+			merged = new(Info)
+		}
+		merged.Upload += info.Upload
+		merged.Download += info.Download
+		merged.Total += info.Total
+		if info.Total == 0 {
+			unlimited = true
+		}
+		if !info.Expire.IsZero() && (merged.Expire.IsZero() || info.Expire.Before(merged.Expire)) {
+			merged.Expire = info.Expire
+		}
+	}
+	if unlimited {
+		merged.Total = 0
+	}
+	return merged
+}
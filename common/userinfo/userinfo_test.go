@@ -0,0 +1,42 @@
+package userinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	info, err := Parse("upload=455727941; download=6174315083; total=1073741824000; expire=1767225600")
+	require.NoError(t, err)
+	require.Equal(t, &Info{
+		Upload:   455727941,
+		Download: 6174315083,
+		Total:    1073741824000,
+		Expire:   time.Unix(1767225600, 0),
+	}, info)
+	require.Equal(t, "upload=455727941; download=6174315083; total=1073741824000; expire=1767225600", info.String())
+	info, err = Parse("upload=0;download=1.5e+09;total=0;expire=")
+	require.NoError(t, err)
+	require.Equal(t, &Info{Download: 1500000000}, info)
+	_, err = Parse("upload=abc")
+	require.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, Merge(nil, nil))
+	require.Equal(t, &Info{
+		Upload:   3,
+		Download: 30,
+		Total:    300,
+		Expire:   time.Unix(100, 0),
+	}, Merge(
+		&Info{Upload: 1, Download: 10, Total: 100, Expire: time.Unix(200, 0)},
+		nil,
+		&Info{Upload: 2, Download: 20, Total: 200, Expire: time.Unix(100, 0)},
+	))
+	require.Zero(t, Merge(&Info{Total: 100}, &Info{}).Total)
+}
@@ -16,6 +16,7 @@ type _Options struct {
 	Listen     string                    `json:"listen,omitempty"`
 	TLS        *option.InboundTLSOptions `json:"tls,omitempty"`
 	CacheFile  string                    `json:"cache_file,omitempty"`
+	GeoIPPath  string                    `json:"geoip_path,omitempty"`
 
 	Outbounds     []badoption.Listable[option.Outbound] `json:"outbounds,omitempty"`
 	Subscriptions []Subscription                        `json:"subscriptions,omitempty"`
@@ -44,15 +45,28 @@ type User struct {
 
 const (
 	DefaultSubscriptionUpdateInterval = 1 * time.Hour
+	DefaultSubscriptionHistorySize    = 5
 )
 
 type Subscription struct {
 	Name             string                                     `json:"name,omitempty"`
 	URL              string                                     `json:"url,omitempty"`
+	Content          string                                     `json:"content,omitempty"`
+	Include          badoption.Listable[string]                 `json:"include,omitempty"`
+	Format           string                                     `json:"format,omitempty"`
 	UserAgent        string                                     `json:"user_agent,omitempty"`
+	Detour           string                                     `json:"detour,omitempty"`
+	Headers          badoption.HTTPHeader                       `json:"headers,omitempty"`
+	Query            map[string]string                          `json:"query,omitempty"`
+	BasicAuth        *SubscriptionBasicAuth                     `json:"basic_auth,omitempty"`
+	BearerToken      string                                     `json:"bearer_token,omitempty"`
 	UpdateInterval   badoption.Duration                         `json:"update_interval,omitempty"`
 	Process          badoption.Listable[OutboundProcessOptions] `json:"process,omitempty"`
 	DeDuplication    bool                                       `json:"deduplication,omitempty"`
+	Strict           bool                                       `json:"strict,omitempty"`
+	MinServers       int                                        `json:"min_servers,omitempty"`
+	MaxDropPercent   int                                        `json:"max_drop_percent,omitempty"`
+	HistorySize      int                                        `json:"history_size,omitempty"`
 	GenerateSelector bool                                       `json:"generate_selector,omitempty"`
 	GenerateURLTest  bool                                       `json:"generate_urltest,omitempty"`
 	URLTestTagSuffix string                                     `json:"urltest_suffix,omitempty"`
@@ -60,16 +74,24 @@ type Subscription struct {
 	CustomURLTest    *option.URLTestOutboundOptions             `json:"custom_urltest,omitempty"`
 }
 
+type SubscriptionBasicAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
 type OutboundProcessOptions struct {
 	Filter           badoption.Listable[string]        `json:"filter,omitempty"`
 	Exclude          badoption.Listable[string]        `json:"exclude,omitempty"`
 	FilterType       badoption.Listable[string]        `json:"filter_type,omitempty"`
 	ExcludeType      badoption.Listable[string]        `json:"exclude_type,omitempty"`
+	FilterCountry    badoption.Listable[string]        `json:"filter_country,omitempty"`
+	ExcludeCountry   badoption.Listable[string]        `json:"exclude_country,omitempty"`
 	Invert           bool                              `json:"invert,omitempty"`
 	Remove           bool                              `json:"remove,omitempty"`
 	Rename           *badjson.TypedMap[string, string] `json:"rename,omitempty"`
 	RemoveEmoji      bool                              `json:"remove_emoji,omitempty"`
 	RewriteMultiplex *option.OutboundMultiplexOptions  `json:"rewrite_multiplex,omitempty"`
+	Patch            json.RawMessage                   `json:"patch,omitempty"`
 }
 
 type Profile struct {
@@ -3,8 +3,10 @@ package server
 import (
 	"context"
 	"regexp"
+	"time"
 
 	"github.com/sagernet/serenity/common/metadata"
+	"github.com/sagernet/serenity/common/userinfo"
 	"github.com/sagernet/serenity/option"
 	"github.com/sagernet/serenity/subscription"
 	"github.com/sagernet/serenity/template"
@@ -148,7 +150,7 @@ func (p *Profile) Render(metadata metadata.Metadata) (*boxOption.Options, error)
 		}
 		subscriptions = append(subscriptions, subscription)
 	}
-	options, err := selectedTemplate.Render(p.manager.ctx, metadata, p.Name, outbounds, subscriptions)
+	options, err := selectedTemplate.Render(p.manager.ctx, p.manager.logger, metadata, p.Name, outbounds, subscriptions)
 	if err != nil {
 		return nil, err
 	}
@@ -158,3 +160,70 @@ func (p *Profile) Render(metadata metadata.Metadata) (*boxOption.Options, error)
 	}
 	return options, nil
 }
+
+// UserInfo returns the merged Subscription-Userinfo of all subscriptions in the profile,
+// counting members of composite subscriptions once.
+func (p *Profile) UserInfo() *userinfo.Info {
+	return userinfo.Merge(common.Map(p.updatedSubscriptions(), func(it *subscription.Subscription) *userinfo.Info {
+		return it.State().UserInfo
+	})...)
+}
+
+// RejectedSubscriptions returns the names of the subscriptions in the profile whose last update was rejected,
+// so that the previous servers are still served.
+func (p *Profile) RejectedSubscriptions() []string {
+	return common.Map(common.Filter(p.updatedSubscriptions(), (*subscription.Subscription).Rejected), func(it *subscription.Subscription) string {
+		return it.Name
+	})
+}
+
+type SubscriptionStatus struct {
+	Name        string     `json:"name"`
+	Servers     int        `json:"servers"`
+	Endpoints   int        `json:"endpoints,omitempty"`
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Rejected    bool       `json:"rejected,omitempty"`
+}
+
+// Status returns the update status of the subscriptions in the profile,
+// with composite subscriptions replaced by their members.
+func (p *Profile) Status() []SubscriptionStatus {
+	return common.Map(p.updatedSubscriptions(), func(it *subscription.Subscription) SubscriptionStatus {
+		state := it.State()
+		status := SubscriptionStatus{
+			Name:      it.Name,
+			Servers:   len(state.Servers),
+			Endpoints: len(state.Endpoints),
+			Rejected:  it.Rejected(),
+		}
+		if !state.LastUpdated.IsZero() {
+			status.LastUpdated = &state.LastUpdated
+		}
+		if !state.LastAttempt.IsZero() {
+			status.LastAttempt = &state.LastAttempt
+		}
+		if state.LastError != nil {
+			status.LastError = state.LastError.Error()
+		}
+		return status
+	})
+}
+
+// updatedSubscriptions returns the subscriptions in the profile that are fetched, with composite subscriptions
+// replaced by their members.
+func (p *Profile) updatedSubscriptions() []*subscription.Subscription {
+	var subscriptions []*subscription.Subscription
+	for _, it := range p.manager.subscription.Subscriptions() {
+		if !common.Contains(p.Subscription, it.Name) {
+			continue
+		}
+		if it.IsComposite() {
+			subscriptions = append(subscriptions, it.Members()...)
+		} else {
+			subscriptions = append(subscriptions, it)
+		}
+	}
+	return common.Uniq(subscriptions)
+}
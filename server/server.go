@@ -79,11 +79,16 @@ func New(ctx context.Context, options option.Options) (*Server, error) {
 	} else {
 		cacheFilePath = "cache.db"
 	}
-	cacheFile := cachefile.New(cacheFilePath)
+	cacheFile := cachefile.New(ctx, cacheFilePath)
+	outbounds := common.Map(options.Outbounds, func(it badoption.Listable[boxOption.Outbound]) []boxOption.Outbound {
+		return it
+	})
 	subscriptionManager, err := subscription.NewSubscriptionManager(
 		ctx,
 		logFactory.NewLogger("subscription"),
 		cacheFile,
+		options.GeoIPPath,
+		outbounds,
 		options.Subscriptions)
 	if err != nil {
 		return nil, err
@@ -100,9 +105,7 @@ func New(ctx context.Context, options option.Options) (*Server, error) {
 		logFactory.NewLogger("profile"),
 		subscriptionManager,
 		templateManager,
-		common.Map(options.Outbounds, func(it badoption.Listable[boxOption.Outbound]) []boxOption.Outbound {
-			return it
-		}),
+		outbounds,
 		options.Profiles,
 	)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sagernet/serenity/common/metadata"
 	M "github.com/sagernet/serenity/common/metadata"
+	"github.com/sagernet/serenity/common/userinfo"
 	"github.com/sagernet/serenity/option"
 	boxOption "github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
@@ -18,14 +19,20 @@ import (
 	"github.com/go-chi/render"
 )
 
+// RejectedHeaderName lists the subscriptions of a profile whose last update was refused by
+// `min_servers` or `max_drop_percent`.
+const RejectedHeaderName = "Serenity-Rejected-Subscriptions"
+
 func (s *Server) initializeRoutes() {
 	s.chiRouter.Use(cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET"},
 		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{userinfo.HeaderName, RejectedHeaderName},
 	}).Handler)
 	s.chiRouter.Get("/", s.render)
 	s.chiRouter.Get("/{profileName}", s.render)
+	s.chiRouter.Get("/{profileName}/status", s.status)
 }
 
 func (s *Server) render(writer http.ResponseWriter, request *http.Request) {
@@ -37,6 +44,44 @@ func (s *Server) render(writer http.ResponseWriter, request *http.Request) {
 	if strings.HasSuffix(profileName, "/") {
 		profileName = profileName[:len(profileName)-1]
 	}
+	profile := s.requestProfile(writer, request, profileName)
+	if profile == nil {
+		return
+	}
+	options, err := profile.Render(M.Detect(request.Header.Get("User-Agent")))
+	if err != nil {
+		s.logger.Error(E.Cause(err, "render options"))
+		render.Status(request, http.StatusInternalServerError)
+		render.PlainText(writer, request, err.Error())
+		s.accessLog(request, http.StatusInternalServerError, len(err.Error()))
+		return
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoderContext(s.ctx, &buffer)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(&options)
+	if err != nil {
+		s.logger.Error(E.Cause(err, "marshal options"))
+		render.Status(request, http.StatusInternalServerError)
+		render.PlainText(writer, request, err.Error())
+		s.accessLog(request, http.StatusInternalServerError, len(err.Error()))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if userInfo := profile.UserInfo(); userInfo != nil {
+		writer.Header().Set(userinfo.HeaderName, userInfo.String())
+	}
+	if rejected := profile.RejectedSubscriptions(); len(rejected) > 0 {
+		writer.Header().Set(RejectedHeaderName, strings.Join(rejected, ", "))
+	}
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(buffer.Bytes())
+	s.accessLog(request, http.StatusOK, buffer.Len())
+}
+
+// requestProfile returns the profile requested by the authorized user,
+// or writes the error response and returns nil.
+func (s *Server) requestProfile(writer http.ResponseWriter, request *http.Request, profileName string) *Profile {
 	var profile *Profile
 	if len(s.users) == 0 {
 		if profileName == "" {
@@ -49,12 +94,12 @@ func (s *Server) render(writer http.ResponseWriter, request *http.Request) {
 		if user == nil {
 			writer.WriteHeader(http.StatusUnauthorized)
 			s.accessLog(request, http.StatusUnauthorized, 0)
-			return
+			return nil
 		}
 		if len(user.Profile) == 0 {
 			writer.WriteHeader(http.StatusNotFound)
 			s.accessLog(request, http.StatusNotFound, 0)
-			return
+			return nil
 		}
 		if profileName == "" {
 			profileName = user.DefaultProfile
@@ -65,29 +110,30 @@ func (s *Server) render(writer http.ResponseWriter, request *http.Request) {
 		if !common.Contains(user.Profile, profileName) {
 			writer.WriteHeader(http.StatusNotFound)
 			s.accessLog(request, http.StatusNotFound, 0)
-			return
+			return nil
 		}
 		profile = s.profile.ProfileByName(profileName)
 	}
 	if profile == nil {
 		writer.WriteHeader(http.StatusNotFound)
 		s.accessLog(request, http.StatusNotFound, 0)
-		return
+		return nil
 	}
-	options, err := profile.Render(M.Detect(request.Header.Get("User-Agent")))
-	if err != nil {
-		s.logger.Error(E.Cause(err, "render options"))
-		render.Status(request, http.StatusInternalServerError)
-		render.PlainText(writer, request, err.Error())
-		s.accessLog(request, http.StatusInternalServerError, len(err.Error()))
+	return profile
+}
+
+// status writes the update status of the subscriptions in the profile.
+func (s *Server) status(writer http.ResponseWriter, request *http.Request) {
+	profile := s.requestProfile(writer, request, chi.URLParam(request, "profileName"))
+	if profile == nil {
 		return
 	}
 	var buffer bytes.Buffer
-	encoder := json.NewEncoderContext(s.ctx, &buffer)
+	encoder := json.NewEncoder(&buffer)
 	encoder.SetIndent("", "  ")
-	err = encoder.Encode(&options)
+	err := encoder.Encode(profile.Status())
 	if err != nil {
-		s.logger.Error(E.Cause(err, "marshal options"))
+		s.logger.Error(E.Cause(err, "marshal status"))
 		render.Status(request, http.StatusInternalServerError)
 		render.PlainText(writer, request, err.Error())
 		s.accessLog(request, http.StatusInternalServerError, len(err.Error()))
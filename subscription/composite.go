@@ -0,0 +1,133 @@
+package subscription
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/serenity/common/userinfo"
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+// IsComposite reports whether the subscription is built from the servers of other subscriptions.
+func (s *Subscription) IsComposite() bool {
+	return len(s.Include) > 0
+}
+
+// Members returns the subscriptions included by a composite subscription.
+func (s *Subscription) Members() []*Subscription {
+	return s.members
+}
+
+func resolveMembers(subscriptions []*Subscription) error {
+	for _, subscription := range subscriptions {
+		if !subscription.IsComposite() {
+			continue
+		}
+		for _, memberName := range subscription.Include {
+			member := common.Find(subscriptions, func(it *Subscription) bool {
+				return it.Name == memberName
+			})
+			switch {
+			case member == nil:
+				return E.New("initialize subscription[", subscription.Name, "]: included subscription not found: ", memberName)
+			case member.IsComposite():
+				return E.New("initialize subscription[", subscription.Name, "]: included subscription ", memberName, " is also composite")
+			case common.Contains(subscription.members, member):
+				return E.New("initialize subscription[", subscription.Name, "]: duplicate included subscription: ", memberName)
+			}
+			subscription.members = append(subscription.members, member)
+		}
+	}
+	return nil
+}
+
+// refreshComposites rebuilds the composite subscriptions that include the member.
+func (m *Manager) refreshComposites(member *Subscription) {
+	for _, subscription := range m.subscriptions {
+		if common.Contains(subscription.members, member) {
+			m.refreshComposite(subscription, true)
+		}
+	}
+}
+
+// refreshComposite collects the processed servers of all members as the raw servers of the composite.
+// Servers are copied, so that the process pipeline of the composite does not modify those of the members,
+// and tags used by more than one member are suffixed with the member name.
+func (m *Manager) refreshComposite(subscription *Subscription, onUpdate bool) {
+	// resolved before taking the lock, so that a slow lookup does not block refreshes triggered by other members
+	if m.countryReady && m.needCountry(subscription) {
+		var memberServers []boxOption.Outbound
+		for _, member := range subscription.members {
+			memberServers = append(memberServers, member.State().Servers...)
+		}
+		m.resolveCountries(memberServers)
+	}
+	m.compositeAccess.Lock()
+	defer m.compositeAccess.Unlock()
+	var (
+		rawServers   []boxOption.Outbound
+		rawEndpoints []boxOption.Endpoint
+		userInfos    []*userinfo.Info
+		lastUpdated  time.Time
+	)
+	tagOwner := make(map[string]string)
+	uniqueTag := func(tag string, memberName string) string {
+		if _, loaded := tagOwner[tag]; loaded {
+			tag = tag + " - " + memberName
+		}
+		tagOwner[tag] = memberName
+		return tag
+	}
+	for _, member := range subscription.members {
+		memberState := member.State()
+		servers, err := cloneOptions(m.ctx, memberState.Servers)
+		if err != nil {
+			m.logger.Error("refresh subscription ", subscription.Name, ": copy servers of ", member.Name, ": ", err)
+			return
+		}
+		endpoints, err := cloneOptions(m.ctx, memberState.Endpoints)
+		if err != nil {
+			m.logger.Error("refresh subscription ", subscription.Name, ": copy endpoints of ", member.Name, ": ", err)
+			return
+		}
+		for index := range servers {
+			servers[index].Tag = uniqueTag(servers[index].Tag, member.Name)
+		}
+		for index := range endpoints {
+			endpoints[index].Tag = uniqueTag(endpoints[index].Tag, member.Name)
+		}
+		rawServers = append(rawServers, servers...)
+		rawEndpoints = append(rawEndpoints, endpoints...)
+		userInfos = append(userInfos, memberState.UserInfo)
+		if memberState.LastUpdated.After(lastUpdated) {
+			lastUpdated = memberState.LastUpdated
+		}
+	}
+	subscription.rawServers = rawServers
+	subscription.rawEndpoints = rawEndpoints
+	subscription.userInfo = userinfo.Merge(userInfos...)
+	subscription.lastUpdated = lastUpdated
+	m.processSubscription(subscription, onUpdate)
+	if onUpdate {
+		m.logUpdated(subscription)
+	}
+}
+
+func cloneOptions[T any](ctx context.Context, options []T) ([]T, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	content, err := json.MarshalContext(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	var cloned []T
+	err = json.UnmarshalContext(ctx, content, &cloned)
+	if err != nil {
+		return nil, err
+	}
+	return cloned, nil
+}
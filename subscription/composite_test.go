@@ -0,0 +1,103 @@
+package subscription
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompositeSubscription(t *testing.T) {
+	t.Parallel()
+	manager := newTestManager(t, option.Subscription{
+		Name:    "a",
+		Content: "ss://YWVzLTEyOC1nY206cGFzcw@a.example.com:8388#HK\nss://YWVzLTEyOC1nY206cGFzcw@a.example.com:8389#JP\n",
+	}, option.Subscription{
+		Name:    "b",
+		Content: "ss://YWVzLTEyOC1nY206cGFzcw@b.example.com:8388#HK\n",
+	}, option.Subscription{
+		Name:    "asia",
+		Include: []string{"a", "b"},
+		Process: []option.OutboundProcessOptions{{
+			Filter: []string{"HK"},
+		}, {
+			Exclude: []string{"HK"},
+			Remove:  true,
+		}},
+	})
+	member, composite := manager.Subscriptions()[0], manager.Subscriptions()[2]
+	tags := func(servers []boxOption.Outbound) []string {
+		return common.Map(servers, func(it boxOption.Outbound) string {
+			return it.Tag
+		})
+	}
+	require.Equal(t, []string{"HK", "HK - b"}, tags(composite.State().Servers))
+	require.Equal(t, []string{"HK", "JP"}, tags(member.State().Servers))
+
+	_, err := manager.parseContent(member, []byte("ss://YWVzLTEyOC1nY206cGFzcw@a.example.com:8388#HK 2\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"HK 2", "HK"}, tags(composite.State().Servers))
+
+	for _, testCase := range []struct {
+		include []string
+		err     string
+	}{
+		{[]string{"missing"}, "included subscription not found: missing"},
+		{[]string{"asia"}, "is also composite"},
+		{[]string{"a", "a"}, "duplicate included subscription: a"},
+	} {
+		_, err = createTestManager(t, nil, "", nil,
+			option.Subscription{Name: "a", Content: "ss://YWVzLTEyOC1nY206cGFzcw@a.example.com:8388#HK\n"},
+			option.Subscription{Name: "asia", Include: []string{"a"}},
+			option.Subscription{Name: "test", Include: testCase.include},
+		)
+		require.ErrorContains(t, err, testCase.err)
+	}
+}
+
+func TestCompositeConcurrentUpdate(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// new content on every request, so that every update replaces the servers
+		fmt.Fprint(writer, "ss://YWVzLTEyOC1nY206cGFzcw@", request.URL.Path[1:], ".example.com:8388#", requests.Add(1), "\n")
+	}))
+	defer server.Close()
+	manager := newTestManager(t,
+		option.Subscription{Name: "a", URL: server.URL + "/a"},
+		option.Subscription{Name: "b", URL: server.URL + "/b"},
+		option.Subscription{Name: "all", Include: []string{"a", "b"}},
+	)
+	composite := manager.Subscriptions()[2]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for requests.Load() < 20 {
+			for _, server := range composite.State().Servers {
+				require.NotEmpty(t, server.Tag)
+			}
+		}
+	}()
+	var waitGroup sync.WaitGroup
+	for _, member := range composite.Members() {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := 0; i < 10; i++ {
+				manager.scheduledUpdate(member)
+				require.NoError(t, member.State().LastError)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	<-done
+	require.Len(t, composite.State().Servers, 2)
+}
@@ -0,0 +1,85 @@
+package subscription
+
+import (
+	"context"
+	"net/netip"
+
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/task"
+)
+
+// CountryLookup returns the ISO country code of the server address, or an empty string if unknown.
+type CountryLookup func(server boxOption.Outbound) string
+
+// needCountry reports whether the processes of the subscription match servers by country.
+func (m *Manager) needCountry(subscription *Subscription) bool {
+	return m.geoIPReader != nil && common.Any(subscription.processes, (*ProcessOptions).NeedCountry)
+}
+
+// resolveCountries resolves the addresses of the servers and caches their countries for lookupCountry.
+func (m *Manager) resolveCountries(servers []boxOption.Outbound) {
+	var resolveGroup task.Group
+	addresses := make(map[string]bool)
+	for _, server := range servers {
+		address := serverAddress(server)
+		if address == "" || addresses[address] {
+			continue
+		}
+		addresses[address] = true
+		resolveGroup.Append0(func(ctx context.Context) error {
+			var country string
+			destination := resolveDestination(m.resolveCtx, server)
+			if destination.IsValid() {
+				country = m.geoIPReader.Lookup(destination.Addr())
+			}
+			m.countryAccess.Lock()
+			m.countries[address] = country
+			m.countryAccess.Unlock()
+			return nil
+		})
+	}
+	resolveGroup.Concurrency(5)
+	_ = resolveGroup.Run(m.ctx)
+}
+
+// processCountryPending processes the subscriptions again that were processed without countries in Start
+// and not updated since.
+func (m *Manager) processCountryPending() {
+	var processed []*Subscription
+	for _, subscription := range m.subscriptions {
+		if subscription.countryPending && !subscription.IsComposite() {
+			m.processSubscription(subscription, false)
+			processed = append(processed, subscription)
+		}
+	}
+	for _, subscription := range m.subscriptions {
+		if !subscription.IsComposite() {
+			continue
+		}
+		if subscription.countryPending || common.Any(subscription.members, func(it *Subscription) bool {
+			return common.Contains(processed, it)
+		}) {
+			m.refreshComposite(subscription, false)
+		}
+	}
+}
+
+// lookupCountry returns the country of the server address cached by resolveCountries.
+func (m *Manager) lookupCountry(server boxOption.Outbound) string {
+	m.countryAccess.RLock()
+	defer m.countryAccess.RUnlock()
+	return m.countries[serverAddress(server)]
+}
+
+func serverAddress(server boxOption.Outbound) string {
+	serverOptionsWrapper, loaded := server.Options.(boxOption.ServerOptionsWrapper)
+	if !loaded {
+		return ""
+	}
+	serverOptions := serverOptionsWrapper.TakeServerOptions()
+	if addr, err := netip.ParseAddr(serverOptions.Server); err == nil {
+		return addr.Unmap().String()
+	}
+	return serverOptions.Server
+}
@@ -0,0 +1,48 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountryAfterStart(t *testing.T) {
+	t.Parallel()
+	filterUS := []option.OutboundProcessOptions{{
+		FilterCountry: []string{"US"},
+		Invert:        true,
+		Remove:        true,
+	}}
+	manager, err := createTestManager(t, newTestCacheFile(t), "../common/geoip/testdata/country.mmdb", nil, option.Subscription{
+		Name:    "a",
+		Content: "trojan://password@1.1.1.1:443#US\ntrojan://password@203.0.113.1:443#other\n",
+		Process: filterUS,
+	}, option.Subscription{
+		Name:    "b",
+		Content: "trojan://password@1.0.0.1:443#US 2\ntrojan://password@203.0.113.2:443#other 2\n",
+	}, option.Subscription{
+		Name:    "all",
+		Include: []string{"a", "b"},
+		Process: filterUS,
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	tags := func(subscription *Subscription) []string {
+		return common.Map(subscription.State().Servers, func(it boxOption.Outbound) string {
+			return it.Tag
+		})
+	}
+	a, b, composite := manager.Subscriptions()[0], manager.Subscriptions()[1], manager.Subscriptions()[2]
+	// country rules are not applied until countries are resolved in PostStart
+	require.Equal(t, []string{"US", "other"}, tags(a))
+	require.Equal(t, []string{"US 2", "other 2"}, tags(b))
+	require.Equal(t, []string{"US", "other", "US 2", "other 2"}, tags(composite))
+
+	require.NoError(t, manager.PostStart(true))
+	require.Equal(t, []string{"US"}, tags(a))
+	require.Equal(t, []string{"US", "US 2"}, tags(composite))
+}
@@ -13,21 +13,7 @@ import (
 	"github.com/sagernet/sing/common/task"
 )
 
-func Deduplication(ctx context.Context, servers []option.Outbound) []option.Outbound {
-	resolveCtx := &resolveContext{
-		ctx: ctx,
-		dnsClient: dns.NewClient(dns.ClientOptions{
-			DisableExpire: true,
-			Logger:        log.NewNOPFactory().Logger(),
-		}),
-		dnsTransport: common.Must1(dns.NewTLSTransport(dns.TransportOptions{
-			Context:      ctx,
-			Dialer:       N.SystemDialer,
-			Address:      "tls://1.1.1.1",
-			ClientSubnet: netip.MustParsePrefix("114.114.114.114/24"),
-		})),
-	}
-
+func deduplication(resolveCtx *resolveContext, servers []option.Outbound) []option.Outbound {
 	uniqueServers := make([]netip.AddrPort, len(servers))
 	var (
 		resolveGroup task.Group
@@ -47,7 +33,7 @@ func Deduplication(ctx context.Context, servers []option.Outbound) []option.Outb
 		})
 	}
 	resolveGroup.Concurrency(5)
-	_ = resolveGroup.Run(ctx)
+	_ = resolveGroup.Run(resolveCtx.ctx)
 	uniqueServerMap := make(map[netip.AddrPort]bool)
 	var newServers []option.Outbound
 	for index, server := range servers {
@@ -69,6 +55,26 @@ type resolveContext struct {
 	dnsTransport dns.Transport
 }
 
+func newResolveContext(ctx context.Context) *resolveContext {
+	return &resolveContext{
+		ctx: ctx,
+		dnsClient: dns.NewClient(dns.ClientOptions{
+			DisableExpire: true,
+			Logger:        log.NewNOPFactory().Logger(),
+		}),
+		dnsTransport: common.Must1(dns.NewTLSTransport(dns.TransportOptions{
+			Context:      ctx,
+			Dialer:       N.SystemDialer,
+			Address:      "tls://1.1.1.1",
+			ClientSubnet: netip.MustParsePrefix("114.114.114.114/24"),
+		})),
+	}
+}
+
+func (c *resolveContext) Close() error {
+	return c.dnsTransport.Close()
+}
+
 func resolveDestination(ctx *resolveContext, server option.Outbound) netip.AddrPort {
 	serverOptionsWrapper, loaded := server.Options.(option.ServerOptionsWrapper)
 	if !loaded {
@@ -0,0 +1,66 @@
+package subscription
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	boxOption "github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
+)
+
+// newDetourOutbound creates a standalone sing-box outbound used to fetch subscriptions.
+// Chained outbounds are not supported since there is no outbound manager to resolve their detour.
+func newDetourOutbound(ctx context.Context, logger log.ContextLogger, outbounds [][]boxOption.Outbound, tag string) (adapter.Outbound, error) {
+	var detourOptions *boxOption.Outbound
+	for _, chain := range outbounds {
+		for index := range chain {
+			if chain[index].Tag == tag {
+				detourOptions = &chain[index]
+				break
+			}
+		}
+	}
+	if detourOptions == nil {
+		return nil, E.New("outbound not found: ", tag)
+	}
+	if dialerOptionsWrapper, containsDialerOptions := detourOptions.Options.(boxOption.DialerOptionsWrapper); containsDialerOptions {
+		if dialerOptionsWrapper.TakeDialerOptions().Detour != "" {
+			return nil, E.New("outbound ", tag, ": chained outbounds are not supported")
+		}
+	}
+	registry := service.FromContext[adapter.OutboundRegistry](ctx)
+	if registry == nil {
+		return nil, E.New("missing outbound registry in context")
+	}
+	return registry.CreateOutbound(ctx, nil, logger, detourOptions.Tag, detourOptions.Type, detourOptions.Options)
+}
+
+func startDetourOutbound(outbound adapter.Outbound) error {
+	lifecycle, isLifecycle := outbound.(adapter.Lifecycle)
+	if !isLifecycle {
+		return nil
+	}
+	for _, stage := range adapter.ListStartStages {
+		err := lifecycle.Start(stage)
+		if err != nil {
+			return E.Cause(err, stage, " outbound ", outbound.Tag())
+		}
+	}
+	return nil
+}
+
+func newDetourHTTPClient(outbound adapter.Outbound) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return outbound.DialContext(ctx, network, M.ParseSocksaddr(address))
+			},
+			ForceAttemptHTTP2: true,
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package subscription
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	C "github.com/sagernet/sing-box/constant"
+	boxOption "github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serveConnectProxy runs a minimal HTTP CONNECT proxy and counts tunneled connections.
+func serveConnectProxy(t *testing.T, connections *atomic.Int32) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				request, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || request.Method != http.MethodConnect {
+					return
+				}
+				upstream, err := net.Dial("tcp", request.Host)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				connections.Add(1)
+				_, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				if err != nil {
+					return
+				}
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener
+}
+
+func TestSubscriptionDetour(t *testing.T) {
+	t.Parallel()
+	origin := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		io.WriteString(writer, "ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example\n")
+	}))
+	defer origin.Close()
+	var connections atomic.Int32
+	proxyListener := serveConnectProxy(t, &connections)
+	defer proxyListener.Close()
+	proxyAddr := proxyListener.Addr().(*net.TCPAddr)
+
+	manager, err := createTestManager(t, newTestCacheFile(t), "", [][]boxOption.Outbound{
+		{
+			{
+				Type: C.TypeHTTP,
+				Tag:  "proxy",
+				Options: &boxOption.HTTPOutboundOptions{
+					ServerOptions: boxOption.ServerOptions{
+						Server:     proxyAddr.IP.String(),
+						ServerPort: uint16(proxyAddr.Port),
+					},
+				},
+			},
+		},
+	}, option.Subscription{
+		Name:   "test",
+		URL:    origin.URL,
+		Detour: "proxy",
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	subscription := manager.Subscriptions()[0]
+	require.NoError(t, manager.update(subscription))
+	require.Len(t, subscription.State().Servers, 1)
+	require.Equal(t, "example", subscription.State().Servers[0].Tag)
+	require.Equal(t, int32(1), connections.Load())
+
+	_, err = createTestManager(t, nil, "", nil, option.Subscription{
+		Name:   "test",
+		URL:    origin.URL,
+		Detour: "missing",
+	})
+	require.ErrorContains(t, err, "outbound not found: missing")
+}
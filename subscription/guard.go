@@ -0,0 +1,32 @@
+package subscription
+
+import (
+	"errors"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// ErrUpdateRejected is wrapped by update errors of content refused by `min_servers` or `max_drop_percent`.
+var ErrUpdateRejected = E.New("update rejected")
+
+// checkUpdate rejects parsed content with too few servers, or with too many lost compared to the current content,
+// so that a provider briefly serving an error page or an empty list does not replace good servers.
+func (s *Subscription) checkUpdate(serverCount int) error {
+	if s.MinServers > 0 && serverCount < s.MinServers {
+		return E.Extend(ErrUpdateRejected, serverCount, " servers, less than min_servers ", s.MinServers)
+	}
+	currentCount := len(s.rawServers) + len(s.rawEndpoints)
+	if s.MaxDropPercent > 0 && currentCount > 0 && serverCount < currentCount {
+		dropPercent := (currentCount - serverCount) * 100 / currentCount
+		if dropPercent > s.MaxDropPercent {
+			return E.Extend(ErrUpdateRejected, serverCount, " servers, dropped ", dropPercent, "% from ", currentCount, ", more than max_drop_percent ", s.MaxDropPercent)
+		}
+	}
+	return nil
+}
+
+// Rejected reports whether the last update was refused by `min_servers` or `max_drop_percent`,
+// in which case the previous content is still in use.
+func (s *Subscription) Rejected() bool {
+	return errors.Is(s.State().LastError, ErrUpdateRejected)
+}
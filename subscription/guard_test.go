@@ -0,0 +1,76 @@
+package subscription
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	boxOption "github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUpdate(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		minServers     int
+		maxDropPercent int
+		current        int
+		updated        int
+		rejected       bool
+	}{
+		{0, 0, 100, 1, false},
+		{10, 0, 0, 9, true},
+		{10, 0, 0, 10, false},
+		{0, 50, 100, 50, false},
+		{0, 50, 100, 49, true},
+		{0, 50, 0, 1, false},
+		{0, 50, 10, 20, false},
+		{5, 50, 10, 4, true},
+	} {
+		subscription := &Subscription{
+			Subscription: option.Subscription{
+				MinServers:     testCase.minServers,
+				MaxDropPercent: testCase.maxDropPercent,
+			},
+			rawServers: make([]boxOption.Outbound, testCase.current),
+		}
+		err := subscription.checkUpdate(testCase.updated)
+		if testCase.rejected {
+			require.ErrorIs(t, err, ErrUpdateRejected, testCase)
+			subscription.publish(func(state *State) {
+				state.LastError = err
+			})
+			require.True(t, subscription.Rejected())
+		} else {
+			require.NoError(t, err, testCase)
+		}
+	}
+}
+
+func TestRejectedUpdate(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if requests.Add(1) == 1 {
+			io.WriteString(writer, "trojan://password@1.1.1.1:443#a\ntrojan://password@1.1.1.2:443#b\n")
+			return
+		}
+		io.WriteString(writer, "trojan://password@1.1.1.1:443#a\n")
+	}))
+	defer server.Close()
+	manager := newTestManager(t, option.Subscription{
+		Name:       "test",
+		URL:        server.URL,
+		MinServers: 2,
+	})
+	subscription := manager.Subscriptions()[0]
+	manager.scheduledUpdate(subscription)
+	require.False(t, subscription.Rejected())
+	manager.scheduledUpdate(subscription)
+	require.True(t, subscription.Rejected())
+	require.Len(t, subscription.State().Servers, 2)
+}
@@ -0,0 +1,42 @@
+package subscription
+
+import (
+	"os"
+	"time"
+)
+
+// updateLocal loads an inline or file subscription, skipping files that have not changed since the last read.
+func (m *Manager) updateLocal(subscription *Subscription) error {
+	if subscription.Content != "" {
+		subscription.lastUpdated = time.Now()
+		_, err := m.parseContent(subscription, []byte(subscription.Content))
+		return err
+	}
+	fileInfo, err := os.Stat(subscription.localPath)
+	if err != nil {
+		return err
+	}
+	if fileInfo.ModTime().Equal(subscription.fileModTime) {
+		return nil
+	}
+	content, err := os.ReadFile(subscription.localPath)
+	if err != nil {
+		return err
+	}
+	updated, err := m.parseContent(subscription, content)
+	if err != nil {
+		return err
+	}
+	subscription.fileModTime = fileInfo.ModTime()
+	subscription.lastUpdated = time.Now()
+	err = m.storeSubscription(subscription)
+	if err != nil {
+		return err
+	}
+	if updated {
+		m.logUpdated(subscription)
+	} else {
+		m.logger.Info("updated subscription ", subscription.Name, ": content unchanged")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package subscription
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sagernet/serenity/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalSubscription(t *testing.T) {
+	t.Parallel()
+	cacheFile := newTestCacheFile(t)
+	path := filepath.Join(t.TempDir(), "nodes.txt")
+	newManager := func() *Manager {
+		manager, err := createTestManager(t, cacheFile, "", nil, option.Subscription{
+			Name: "file",
+			URL:  "file://" + path,
+		}, option.Subscription{
+			Name:    "inline",
+			Content: "ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#inline\n",
+		})
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		return manager
+	}
+
+	// a missing file does not prevent startup
+	manager := newManager()
+	fileSubscription, inlineSubscription := manager.Subscriptions()[0], manager.Subscriptions()[1]
+	require.Equal(t, "inline", inlineSubscription.State().Servers[0].Tag)
+	require.Empty(t, fileSubscription.State().Servers)
+	require.Error(t, manager.update(fileSubscription))
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile := func(tag string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte("ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#"+tag+"\n"), 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeFile("first", modTime)
+	require.NoError(t, manager.update(fileSubscription))
+	require.Equal(t, "first", fileSubscription.State().Servers[0].Tag)
+
+	// files are only read again when the modification time changes
+	writeFile("second", modTime)
+	require.NoError(t, manager.update(fileSubscription))
+	require.Equal(t, "first", fileSubscription.State().Servers[0].Tag)
+	writeFile("second", modTime.Add(time.Minute))
+	require.NoError(t, manager.update(fileSubscription))
+	require.Equal(t, "second", fileSubscription.State().Servers[0].Tag)
+
+	// the cached content is used while the file is unreadable
+	require.NoError(t, os.Remove(path))
+	cachedManager := newManager()
+	require.Equal(t, "second", cachedManager.Subscriptions()[0].State().Servers[0].Tag)
+	require.Error(t, cachedManager.update(cachedManager.Subscriptions()[0]))
+	require.Equal(t, "second", cachedManager.Subscriptions()[0].State().Servers[0].Tag)
+}
+
+func TestSubscriptionSource(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		subscription option.Subscription
+		err          string
+	}{
+		{option.Subscription{Name: "test", URL: "https://example.com", Content: "ss://"}, "`url` and `content` are mutually exclusive"},
+		{option.Subscription{Name: "test"}, "missing `url` or `content`"},
+		{option.Subscription{Name: "test", URL: "file://"}, "missing file path"},
+		{option.Subscription{Name: "test", URL: "file://nodes.txt", Detour: "proxy"}, "`detour` is only supported for remote subscriptions"},
+	} {
+		_, err := createTestManager(t, nil, "", nil, testCase.subscription)
+		require.ErrorContains(t, err, testCase.err)
+	}
+}
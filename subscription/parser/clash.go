@@ -18,157 +18,204 @@ import (
 	"github.com/Dreamacro/clash/constant"
 )
 
-func ParseClashSubscription(_ context.Context, content string) ([]option.Outbound, error) {
+func ParseClashSubscription(_ context.Context, content string) ([]option.Outbound, []option.Endpoint, error) {
 	config, err := config.UnmarshalRawConfig([]byte(content))
 	if err != nil {
-		return nil, E.Cause(err, "parse clash config")
+		return nil, nil, E.Cause(err, "parse clash config")
 	}
 	decoder := structure.NewDecoder(structure.Option{TagName: "proxy", WeaklyTypedInput: true})
-	var outbounds []option.Outbound
+	var (
+		outbounds []option.Outbound
+		endpoints []option.Endpoint
+		warnings  []error
+	)
 	for i, proxyMapping := range config.Proxy {
-		proxy, err := adapter.ParseProxy(proxyMapping)
-		if err != nil {
-			return nil, E.Cause(err, "parse proxy ", i)
+		proxyName, _ := proxyMapping["name"].(string)
+		warn := func(message ...any) {
+			warnings = append(warnings, NewWarning(append([]any{"proxy[", i, "] ", proxyName, ": "}, message...)...))
 		}
-		var outbound option.Outbound
-		outbound.Tag = proxy.Name()
-		switch proxy.Type() {
-		case constant.Shadowsocks:
-			ssOption := &clash_outbound.ShadowSocksOption{}
-			err = decoder.Decode(proxyMapping, ssOption)
-			if err != nil {
-				return nil, err
-			}
-			outbound.Type = C.TypeShadowsocks
-			outbound.Options = &option.ShadowsocksOutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     ssOption.Server,
-					ServerPort: uint16(ssOption.Port),
-				},
-				Password:      ssOption.Password,
-				Method:        clashShadowsocksCipher(ssOption.Cipher),
-				Plugin:        clashPluginName(ssOption.Plugin),
-				PluginOptions: clashPluginOptions(ssOption.Plugin, ssOption.PluginOpts),
-				Network:       clashNetworks(ssOption.UDP),
-			}
-		case constant.ShadowsocksR:
-			ssrOption := &clash_outbound.ShadowSocksROption{}
-			err = decoder.Decode(proxyMapping, ssrOption)
-			if err != nil {
-				return nil, err
-			}
-			outbound.Type = C.TypeShadowsocksR
-			outbound.Options = &option.ShadowsocksROutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     ssrOption.Server,
-					ServerPort: uint16(ssrOption.Port),
-				},
-				Password:      ssrOption.Password,
-				Method:        clashShadowsocksCipher(ssrOption.Cipher),
-				Protocol:      ssrOption.Protocol,
-				ProtocolParam: ssrOption.ProtocolParam,
-				Obfs:          ssrOption.Obfs,
-				ObfsParam:     ssrOption.ObfsParam,
-				Network:       clashNetworks(ssrOption.UDP),
-			}
-		case constant.Trojan:
-			trojanOption := &clash_outbound.TrojanOption{}
-			err = decoder.Decode(proxyMapping, trojanOption)
-			if err != nil {
-				return nil, err
-			}
-			outbound.Type = C.TypeTrojan
-			outbound.Options = &option.TrojanOutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     trojanOption.Server,
-					ServerPort: uint16(trojanOption.Port),
-				},
-				Password: trojanOption.Password,
-				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
-					TLS: &option.OutboundTLSOptions{
-						Enabled:    true,
-						ALPN:       trojanOption.ALPN,
-						ServerName: trojanOption.SNI,
-						Insecure:   trojanOption.SkipCertVerify,
-					},
-				},
-				Transport: clashTransport(trojanOption.Network, clash_outbound.HTTPOptions{}, clash_outbound.HTTP2Options{}, trojanOption.GrpcOpts, trojanOption.WSOpts),
-				Network:   clashNetworks(trojanOption.UDP),
-			}
-		case constant.Vmess:
-			vmessOption := &clash_outbound.VmessOption{}
-			err = decoder.Decode(proxyMapping, vmessOption)
-			if err != nil {
-				return nil, err
-			}
-			outbound.Type = C.TypeVMess
-			outbound.Options = &option.VMessOutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     vmessOption.Server,
-					ServerPort: uint16(vmessOption.Port),
-				},
-				UUID:     vmessOption.UUID,
-				Security: vmessOption.Cipher,
-				AlterId:  vmessOption.AlterID,
-				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
-					TLS: &option.OutboundTLSOptions{
-						Enabled:    vmessOption.TLS,
-						ServerName: vmessOption.ServerName,
-						Insecure:   vmessOption.SkipCertVerify,
-					},
-				},
-				Transport: clashTransport(vmessOption.Network, vmessOption.HTTPOpts, vmessOption.HTTP2Opts, vmessOption.GrpcOpts, vmessOption.WSOpts),
-				Network:   clashNetworks(vmessOption.UDP),
-			}
-		case constant.Socks5:
-			socks5Option := &clash_outbound.Socks5Option{}
-			err = decoder.Decode(proxyMapping, socks5Option)
+		if proxyType, _ := proxyMapping["type"].(string); proxyType == "wireguard" {
+			endpoint, err := parseClashWireGuard(decoder, proxyMapping)
 			if err != nil {
-				return nil, err
-			}
-
-			if socks5Option.TLS {
-				// TODO: print warning
+				warnings = append(warnings, E.Cause(err, "skip proxy[", i, "] ", proxyName))
 				continue
 			}
+			endpoints = append(endpoints, endpoint)
+			continue
+		}
+		outbound, err := parseClashProxy(decoder, proxyMapping)
+		if err == nil {
+			err = clashApplyOutboundTLSOptions(decoder, proxyMapping, outbound, warn)
+		}
+		if err != nil {
+			warnings = append(warnings, E.Cause(err, "skip proxy[", i, "] ", proxyName))
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	if len(outbounds) > 0 || len(endpoints) > 0 {
+		return outbounds, endpoints, E.Errors(warnings...)
+	}
+	if len(warnings) > 0 {
+		return nil, nil, E.Cause(E.Errors(warnings...), "no servers found")
+	}
+	return nil, nil, E.New("no servers found")
+}
 
-			outbound.Type = C.TypeSOCKS
-			outbound.Options = &option.SOCKSOutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     socks5Option.Server,
-					ServerPort: uint16(socks5Option.Port),
+func parseClashProxy(decoder *structure.Decoder, proxyMapping map[string]any) (option.Outbound, error) {
+	if proxyType, _ := proxyMapping["type"].(string); isClashMetaProxyType(proxyType) {
+		return parseClashMetaProxy(decoder, proxyType, proxyMapping)
+	}
+	proxy, err := adapter.ParseProxy(proxyMapping)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	var outbound option.Outbound
+	outbound.Tag = proxy.Name()
+	switch proxy.Type() {
+	case constant.Shadowsocks:
+		ssOption := &clash_outbound.ShadowSocksOption{}
+		err = decoder.Decode(proxyMapping, ssOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outbound.Type = C.TypeShadowsocks
+		outbound.Options = &option.ShadowsocksOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     ssOption.Server,
+				ServerPort: uint16(ssOption.Port),
+			},
+			Password:      ssOption.Password,
+			Method:        clashShadowsocksCipher(ssOption.Cipher),
+			Plugin:        clashPluginName(ssOption.Plugin),
+			PluginOptions: clashPluginOptions(ssOption.Plugin, ssOption.PluginOpts),
+			Network:       clashNetworks(ssOption.UDP),
+		}
+	case constant.ShadowsocksR:
+		ssrOption := &clash_outbound.ShadowSocksROption{}
+		err = decoder.Decode(proxyMapping, ssrOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outbound.Type = C.TypeShadowsocksR
+		outbound.Options = &option.ShadowsocksROutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     ssrOption.Server,
+				ServerPort: uint16(ssrOption.Port),
+			},
+			Password:      ssrOption.Password,
+			Method:        clashShadowsocksCipher(ssrOption.Cipher),
+			Protocol:      ssrOption.Protocol,
+			ProtocolParam: ssrOption.ProtocolParam,
+			Obfs:          ssrOption.Obfs,
+			ObfsParam:     ssrOption.ObfsParam,
+			Network:       clashNetworks(ssrOption.UDP),
+		}
+	case constant.Trojan:
+		trojanOption := &clash_outbound.TrojanOption{}
+		err = decoder.Decode(proxyMapping, trojanOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outbound.Type = C.TypeTrojan
+		outbound.Options = &option.TrojanOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     trojanOption.Server,
+				ServerPort: uint16(trojanOption.Port),
+			},
+			Password: trojanOption.Password,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:    true,
+					ALPN:       trojanOption.ALPN,
+					ServerName: trojanOption.SNI,
+					Insecure:   trojanOption.SkipCertVerify,
 				},
-				Username: socks5Option.UserName,
-				Password: socks5Option.Password,
-				Network:  clashNetworks(socks5Option.UDP),
-			}
-		case constant.Http:
-			httpOption := &clash_outbound.HttpOption{}
-			err = decoder.Decode(proxyMapping, httpOption)
-			if err != nil {
-				return nil, err
-			}
+			},
+			Transport: clashTransport(trojanOption.Network, clash_outbound.HTTPOptions{}, clash_outbound.HTTP2Options{}, trojanOption.GrpcOpts, trojanOption.WSOpts),
+			Network:   clashNetworks(trojanOption.UDP),
+		}
+	case constant.Vmess:
+		vmessOption := &clash_outbound.VmessOption{}
+		err = decoder.Decode(proxyMapping, vmessOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outbound.Type = C.TypeVMess
+		outbound.Options = &option.VMessOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     vmessOption.Server,
+				ServerPort: uint16(vmessOption.Port),
+			},
+			UUID:     vmessOption.UUID,
+			Security: vmessOption.Cipher,
+			AlterId:  vmessOption.AlterID,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:    vmessOption.TLS,
+					ServerName: vmessOption.ServerName,
+					Insecure:   vmessOption.SkipCertVerify,
+				},
+			},
+			Transport: clashTransport(vmessOption.Network, vmessOption.HTTPOpts, vmessOption.HTTP2Opts, vmessOption.GrpcOpts, vmessOption.WSOpts),
+			Network:   clashNetworks(vmessOption.UDP),
+		}
+	case constant.Socks5:
+		socks5Option := &clash_outbound.Socks5Option{}
+		err = decoder.Decode(proxyMapping, socks5Option)
+		if err != nil {
+			return option.Outbound{}, err
+		}
 
-			if httpOption.TLS {
-				continue
-			}
+		if socks5Option.TLS {
+			return option.Outbound{}, E.New("TLS is not supported for SOCKS outbound")
+		}
 
-			outbound.Type = C.TypeHTTP
-			outbound.Options = &option.HTTPOutboundOptions{
-				ServerOptions: option.ServerOptions{
-					Server:     httpOption.Server,
-					ServerPort: uint16(httpOption.Port),
-				},
-				Username: httpOption.UserName,
-				Password: httpOption.Password,
+		outbound.Type = C.TypeSOCKS
+		outbound.Options = &option.SOCKSOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     socks5Option.Server,
+				ServerPort: uint16(socks5Option.Port),
+			},
+			Username: socks5Option.UserName,
+			Password: socks5Option.Password,
+			Network:  clashNetworks(socks5Option.UDP),
+		}
+	case constant.Http:
+		httpOption := &clash_outbound.HttpOption{}
+		err = decoder.Decode(proxyMapping, httpOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		var headers badoption.HTTPHeader
+		for key, value := range httpOption.Headers {
+			if headers == nil {
+				headers = make(badoption.HTTPHeader)
 			}
+			headers[key] = []string{value}
 		}
-		outbounds = append(outbounds, outbound)
-	}
-	if len(outbounds) > 0 {
-		return outbounds, nil
+		httpOutboundOptions := &option.HTTPOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     httpOption.Server,
+				ServerPort: uint16(httpOption.Port),
+			},
+			Username: httpOption.UserName,
+			Password: httpOption.Password,
+			Headers:  headers,
+		}
+		if httpOption.TLS {
+			httpOutboundOptions.TLS = &option.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: httpOption.SNI,
+				Insecure:   httpOption.SkipCertVerify,
+			}
+		}
+		outbound.Type = C.TypeHTTP
+		outbound.Options = httpOutboundOptions
+	default:
+		return option.Outbound{}, E.New("unsupported proxy type: ", proxy.Type().String())
 	}
-	return nil, E.New("no servers found")
+	return outbound, nil
 }
 
 func clashShadowsocksCipher(cipher string) string {
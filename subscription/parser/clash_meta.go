@@ -0,0 +1,482 @@
+package parser
+
+import (
+	"encoding/base64"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	clash_outbound "github.com/Dreamacro/clash/adapter/outbound"
+	"github.com/Dreamacro/clash/common/structure"
+)
+
+// Proxy types below are only available in Clash.Meta (mihomo),
+// so they are decoded here instead of through adapter.ParseProxy.
+
+type clashVLESSOption struct {
+	Name           string                      `proxy:"name"`
+	Server         string                      `proxy:"server"`
+	Port           int                         `proxy:"port"`
+	UUID           string                      `proxy:"uuid"`
+	Flow           string                      `proxy:"flow,omitempty"`
+	TLS            bool                        `proxy:"tls,omitempty"`
+	ALPN           []string                    `proxy:"alpn,omitempty"`
+	UDP            bool                        `proxy:"udp,omitempty"`
+	PacketAddr     bool                        `proxy:"packet-addr,omitempty"`
+	XUDP           bool                        `proxy:"xudp,omitempty"`
+	PacketEncoding string                      `proxy:"packet-encoding,omitempty"`
+	Network        string                      `proxy:"network,omitempty"`
+	HTTPOpts       clash_outbound.HTTPOptions  `proxy:"http-opts,omitempty"`
+	HTTP2Opts      clash_outbound.HTTP2Options `proxy:"h2-opts,omitempty"`
+	GrpcOpts       clash_outbound.GrpcOptions  `proxy:"grpc-opts,omitempty"`
+	WSOpts         clash_outbound.WSOptions    `proxy:"ws-opts,omitempty"`
+	SkipCertVerify bool                        `proxy:"skip-cert-verify,omitempty"`
+	ServerName     string                      `proxy:"servername,omitempty"`
+}
+
+type clashHysteriaOption struct {
+	Name                string   `proxy:"name"`
+	Server              string   `proxy:"server"`
+	Port                int      `proxy:"port,omitempty"`
+	Ports               any      `proxy:"ports,omitempty"`
+	Protocol            string   `proxy:"protocol,omitempty"`
+	ObfsProtocol        string   `proxy:"obfs-protocol,omitempty"`
+	Up                  string   `proxy:"up"`
+	Down                string   `proxy:"down"`
+	Auth                string   `proxy:"auth,omitempty"`
+	AuthString          string   `proxy:"auth-str,omitempty"`
+	Obfs                string   `proxy:"obfs,omitempty"`
+	SNI                 string   `proxy:"sni,omitempty"`
+	SkipCertVerify      bool     `proxy:"skip-cert-verify,omitempty"`
+	ALPN                []string `proxy:"alpn,omitempty"`
+	CustomCA            string   `proxy:"ca,omitempty"`
+	CustomCAString      string   `proxy:"ca-str,omitempty"`
+	ReceiveWindowConn   int      `proxy:"recv-window-conn,omitempty"`
+	ReceiveWindow       int      `proxy:"recv-window,omitempty"`
+	DisableMTUDiscovery bool     `proxy:"disable-mtu-discovery,omitempty"`
+}
+
+type clashHysteria2Option struct {
+	Name           string   `proxy:"name"`
+	Server         string   `proxy:"server"`
+	Port           int      `proxy:"port,omitempty"`
+	Ports          any      `proxy:"ports,omitempty"`
+	Up             string   `proxy:"up,omitempty"`
+	Down           string   `proxy:"down,omitempty"`
+	Password       string   `proxy:"password,omitempty"`
+	Obfs           string   `proxy:"obfs,omitempty"`
+	ObfsPassword   string   `proxy:"obfs-password,omitempty"`
+	SNI            string   `proxy:"sni,omitempty"`
+	SkipCertVerify bool     `proxy:"skip-cert-verify,omitempty"`
+	ALPN           []string `proxy:"alpn,omitempty"`
+	CustomCA       string   `proxy:"ca,omitempty"`
+	CustomCAString string   `proxy:"ca-str,omitempty"`
+}
+
+type clashTUICOption struct {
+	Name                 string   `proxy:"name"`
+	Server               string   `proxy:"server"`
+	Port                 int      `proxy:"port"`
+	Token                string   `proxy:"token,omitempty"`
+	UUID                 string   `proxy:"uuid,omitempty"`
+	Password             string   `proxy:"password,omitempty"`
+	HeartbeatInterval    int      `proxy:"heartbeat-interval,omitempty"`
+	ALPN                 []string `proxy:"alpn,omitempty"`
+	ReduceRTT            bool     `proxy:"reduce-rtt,omitempty"`
+	UDPRelayMode         string   `proxy:"udp-relay-mode,omitempty"`
+	CongestionController string   `proxy:"congestion-controller,omitempty"`
+	DisableSNI           bool     `proxy:"disable-sni,omitempty"`
+	SkipCertVerify       bool     `proxy:"skip-cert-verify,omitempty"`
+	CustomCA             string   `proxy:"ca,omitempty"`
+	CustomCAString       string   `proxy:"ca-str,omitempty"`
+	SNI                  string   `proxy:"sni,omitempty"`
+	UDPOverStream        bool     `proxy:"udp-over-stream,omitempty"`
+}
+
+type clashWireGuardOption struct {
+	clashWireGuardPeerOption `proxy:",squash"`
+	Name                     string                     `proxy:"name"`
+	IP                       string                     `proxy:"ip,omitempty"`
+	IPv6                     string                     `proxy:"ipv6,omitempty"`
+	PrivateKey               string                     `proxy:"private-key"`
+	Workers                  int                        `proxy:"workers,omitempty"`
+	MTU                      int                        `proxy:"mtu,omitempty"`
+	UDP                      bool                       `proxy:"udp,omitempty"`
+	Peers                    []clashWireGuardPeerOption `proxy:"peers,omitempty"`
+}
+
+type clashWireGuardPeerOption struct {
+	Server       string   `proxy:"server"`
+	Port         int      `proxy:"port"`
+	PublicKey    string   `proxy:"public-key,omitempty"`
+	PreSharedKey string   `proxy:"pre-shared-key,omitempty"`
+	Reserved     any      `proxy:"reserved,omitempty"`
+	AllowedIPs   []string `proxy:"allowed-ips,omitempty"`
+}
+
+func parseClashMetaProxy(decoder *structure.Decoder, proxyType string, proxyMapping map[string]any) (option.Outbound, error) {
+	var outbound option.Outbound
+	switch proxyType {
+	case "vless":
+		vlessOption := &clashVLESSOption{}
+		err := decoder.Decode(proxyMapping, vlessOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outboundOptions := &option.VLESSOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     vlessOption.Server,
+				ServerPort: uint16(vlessOption.Port),
+			},
+			UUID: vlessOption.UUID,
+			Flow: vlessOption.Flow,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:    vlessOption.TLS,
+					ALPN:       vlessOption.ALPN,
+					ServerName: vlessOption.ServerName,
+					Insecure:   vlessOption.SkipCertVerify,
+				},
+			},
+			Transport: clashTransport(vlessOption.Network, vlessOption.HTTPOpts, vlessOption.HTTP2Opts, vlessOption.GrpcOpts, vlessOption.WSOpts),
+			Network:   clashNetworks(vlessOption.UDP),
+		}
+		var packetEncoding string
+		switch {
+		case vlessOption.PacketEncoding != "":
+			packetEncoding = vlessOption.PacketEncoding
+		case vlessOption.XUDP:
+			packetEncoding = "xudp"
+		case vlessOption.PacketAddr:
+			packetEncoding = "packetaddr"
+		}
+		if packetEncoding != "" {
+			outboundOptions.PacketEncoding = &packetEncoding
+		}
+		outbound.Type = C.TypeVLESS
+		outbound.Tag = vlessOption.Name
+		outbound.Options = outboundOptions
+	case "hysteria":
+		hysteriaOption := &clashHysteriaOption{}
+		err := decoder.Decode(proxyMapping, hysteriaOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		// `obfs-protocol` is the legacy name of `protocol`
+		for _, protocol := range []string{hysteriaOption.Protocol, hysteriaOption.ObfsProtocol} {
+			if protocol != "" && protocol != "udp" {
+				return option.Outbound{}, E.New("unsupported hysteria protocol: ", protocol)
+			}
+		}
+		var auth []byte
+		if hysteriaOption.Auth != "" {
+			auth, err = base64.StdEncoding.DecodeString(hysteriaOption.Auth)
+			if err != nil {
+				return option.Outbound{}, E.Cause(err, "decode hysteria auth")
+			}
+		}
+		outboundOptions := &option.HysteriaOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     hysteriaOption.Server,
+				ServerPort: clashServerPort(hysteriaOption.Port, hysteriaOption.Ports),
+			},
+			Obfs:                hysteriaOption.Obfs,
+			Auth:                auth,
+			AuthString:          hysteriaOption.AuthString,
+			ReceiveWindowConn:   uint64(hysteriaOption.ReceiveWindowConn),
+			ReceiveWindow:       uint64(hysteriaOption.ReceiveWindow),
+			DisableMTUDiscovery: hysteriaOption.DisableMTUDiscovery,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:         true,
+					ALPN:            hysteriaOption.ALPN,
+					ServerName:      hysteriaOption.SNI,
+					Insecure:        hysteriaOption.SkipCertVerify,
+					CertificatePath: hysteriaOption.CustomCA,
+					Certificate:     clashCertificate(hysteriaOption.CustomCAString),
+				},
+			},
+		}
+		if mbps, isNumber := clashBandwidthNumber(hysteriaOption.Up); isNumber {
+			outboundOptions.UpMbps = mbps
+		} else {
+			outboundOptions.Up = hysteriaOption.Up
+		}
+		if mbps, isNumber := clashBandwidthNumber(hysteriaOption.Down); isNumber {
+			outboundOptions.DownMbps = mbps
+		} else {
+			outboundOptions.Down = hysteriaOption.Down
+		}
+		outbound.Type = C.TypeHysteria
+		outbound.Tag = hysteriaOption.Name
+		outbound.Options = outboundOptions
+	case "hysteria2":
+		hysteria2Option := &clashHysteria2Option{}
+		err := decoder.Decode(proxyMapping, hysteria2Option)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		outboundOptions := &option.Hysteria2OutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     hysteria2Option.Server,
+				ServerPort: clashServerPort(hysteria2Option.Port, hysteria2Option.Ports),
+			},
+			UpMbps:   clashBandwidthMbps(hysteria2Option.Up),
+			DownMbps: clashBandwidthMbps(hysteria2Option.Down),
+			Password: hysteria2Option.Password,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:         true,
+					ALPN:            hysteria2Option.ALPN,
+					ServerName:      hysteria2Option.SNI,
+					Insecure:        hysteria2Option.SkipCertVerify,
+					CertificatePath: hysteria2Option.CustomCA,
+					Certificate:     clashCertificate(hysteria2Option.CustomCAString),
+				},
+			},
+		}
+		if hysteria2Option.Obfs != "" {
+			outboundOptions.Obfs = &option.Hysteria2Obfs{
+				Type:     hysteria2Option.Obfs,
+				Password: hysteria2Option.ObfsPassword,
+			}
+		}
+		outbound.Type = C.TypeHysteria2
+		outbound.Tag = hysteria2Option.Name
+		outbound.Options = outboundOptions
+	case "tuic":
+		tuicOption := &clashTUICOption{}
+		err := decoder.Decode(proxyMapping, tuicOption)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		if tuicOption.UUID == "" {
+			return option.Outbound{}, E.New("TUIC v4 (token authentication) is not supported")
+		}
+		outboundOptions := &option.TUICOutboundOptions{
+			ServerOptions: option.ServerOptions{
+				Server:     tuicOption.Server,
+				ServerPort: uint16(tuicOption.Port),
+			},
+			UUID:              tuicOption.UUID,
+			Password:          tuicOption.Password,
+			CongestionControl: tuicOption.CongestionController,
+			UDPRelayMode:      tuicOption.UDPRelayMode,
+			UDPOverStream:     tuicOption.UDPOverStream,
+			ZeroRTTHandshake:  tuicOption.ReduceRTT,
+			Heartbeat:         badoption.Duration(time.Duration(tuicOption.HeartbeatInterval) * time.Millisecond),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: &option.OutboundTLSOptions{
+					Enabled:         true,
+					DisableSNI:      tuicOption.DisableSNI,
+					ALPN:            tuicOption.ALPN,
+					ServerName:      tuicOption.SNI,
+					Insecure:        tuicOption.SkipCertVerify,
+					CertificatePath: tuicOption.CustomCA,
+					Certificate:     clashCertificate(tuicOption.CustomCAString),
+				},
+			},
+		}
+		outbound.Type = C.TypeTUIC
+		outbound.Tag = tuicOption.Name
+		outbound.Options = outboundOptions
+	default:
+		return option.Outbound{}, E.New("unsupported proxy type: ", proxyType)
+	}
+	return outbound, nil
+}
+
+// parseClashWireGuard converts a WireGuard proxy into an endpoint, with the top-level peer used if `peers` is empty.
+func parseClashWireGuard(decoder *structure.Decoder, proxyMapping map[string]any) (option.Endpoint, error) {
+	wireguardOption := &clashWireGuardOption{}
+	err := decoder.Decode(proxyMapping, wireguardOption)
+	if err != nil {
+		return option.Endpoint{}, err
+	}
+	var localAddress []netip.Prefix
+	for _, address := range []string{wireguardOption.IP, wireguardOption.IPv6} {
+		if address == "" {
+			continue
+		}
+		prefix, err := clashPrefix(address)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse wireguard address")
+		}
+		localAddress = append(localAddress, prefix)
+	}
+	endpointOptions := &option.WireGuardEndpointOptions{
+		Address:    localAddress,
+		PrivateKey: wireguardOption.PrivateKey,
+		Workers:    wireguardOption.Workers,
+		MTU:        uint32(wireguardOption.MTU),
+	}
+	peers := wireguardOption.Peers
+	if len(peers) == 0 {
+		peers = []clashWireGuardPeerOption{wireguardOption.clashWireGuardPeerOption}
+	}
+	for peerIndex, peer := range peers {
+		reserved, err := clashWireGuardReserved(peer.Reserved)
+		if err != nil {
+			return option.Endpoint{}, E.Cause(err, "parse peer[", peerIndex, "]")
+		}
+		var allowedIPs []netip.Prefix
+		for _, allowedIP := range peer.AllowedIPs {
+			prefix, err := clashPrefix(allowedIP)
+			if err != nil {
+				return option.Endpoint{}, E.Cause(err, "parse peer[", peerIndex, "]: parse allowed-ips")
+			}
+			allowedIPs = append(allowedIPs, prefix)
+		}
+		endpointOptions.Peers = append(endpointOptions.Peers, option.WireGuardPeer{
+			Address:      peer.Server,
+			Port:         uint16(peer.Port),
+			PublicKey:    peer.PublicKey,
+			PreSharedKey: peer.PreSharedKey,
+			AllowedIPs:   wireGuardAllowedIPs(allowedIPs),
+			Reserved:     reserved,
+		})
+	}
+	return option.Endpoint{
+		Type:    C.TypeWireGuard,
+		Tag:     wireguardOption.Name,
+		Options: endpointOptions,
+	}, nil
+}
+
+func isClashMetaProxyType(proxyType string) bool {
+	switch proxyType {
+	case "vless", "hysteria", "hysteria2", "tuic":
+		return true
+	default:
+		return false
+	}
+}
+
+// clashServerPort falls back to the first port of `ports` when `port` is missing.
+// Port hopping is not available in sing-box outbound options, so other ports are ignored.
+func clashServerPort(port int, ports any) uint16 {
+	if port != 0 || ports == nil {
+		return uint16(port)
+	}
+	var portsString string
+	switch portsValue := ports.(type) {
+	case int:
+		return uint16(portsValue)
+	case string:
+		portsString = portsValue
+	}
+	if index := strings.IndexAny(portsString, ",-"); index != -1 {
+		portsString = portsString[:index]
+	}
+	return portFromString(strings.TrimSpace(portsString))
+}
+
+// clashBandwidthNumber reports whether a bandwidth is a bare number, which Clash treats as Mbps.
+func clashBandwidthNumber(bandwidth string) (int, bool) {
+	mbps, err := strconv.Atoi(strings.TrimSpace(bandwidth))
+	if err != nil {
+		return 0, false
+	}
+	return mbps, true
+}
+
+func clashBandwidthMbps(bandwidth string) int {
+	bandwidth = strings.TrimSpace(bandwidth)
+	if bandwidth == "" {
+		return 0
+	}
+	numberIndex := strings.IndexFunc(bandwidth, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if numberIndex == -1 {
+		numberIndex = len(bandwidth)
+	}
+	value, err := strconv.ParseFloat(bandwidth[:numberIndex], 64)
+	if err != nil {
+		return 0
+	}
+	unit := strings.TrimSpace(bandwidth[numberIndex:])
+	switch {
+	case strings.HasSuffix(unit, "Bps"):
+		value *= 8
+		unit = strings.TrimSuffix(unit, "Bps")
+	case strings.HasSuffix(unit, "bps"):
+		unit = strings.TrimSuffix(unit, "bps")
+		if unit == "" {
+			value /= 1000000
+		}
+	}
+	switch strings.ToUpper(unit) {
+	case "", "M":
+	case "K":
+		value /= 1000
+	case "G":
+		value *= 1000
+	case "T":
+		value *= 1000000
+	default:
+		return 0
+	}
+	return int(value)
+}
+
+func clashCertificate(certificate string) badoption.Listable[string] {
+	if certificate == "" {
+		return nil
+	}
+	return []string{certificate}
+}
+
+func clashPrefix(address string) (netip.Prefix, error) {
+	if strings.Contains(address, "/") {
+		return netip.ParsePrefix(address)
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// clashWireGuardReserved accepts reserved bytes as a list, a comma separated string or base64.
+func clashWireGuardReserved(reserved any) ([]uint8, error) {
+	switch reservedValue := reserved.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		var result []uint8
+		for index, item := range reservedValue {
+			number, isInt := item.(int)
+			if !isInt || number < 0 || number > 255 {
+				return nil, E.New("invalid reserved[", index, "]")
+			}
+			result = append(result, uint8(number))
+		}
+		return result, nil
+	case string:
+		if strings.Contains(reservedValue, ",") {
+			var result []uint8
+			for _, item := range strings.Split(reservedValue, ",") {
+				number, err := strconv.ParseUint(strings.TrimSpace(item), 10, 8)
+				if err != nil {
+					return nil, E.Cause(err, "parse reserved")
+				}
+				result = append(result, uint8(number))
+			}
+			return result, nil
+		}
+		result, err := base64.StdEncoding.DecodeString(reservedValue)
+		if err != nil {
+			return nil, E.Cause(err, "decode reserved")
+		}
+		return result, nil
+	default:
+		return nil, E.New("invalid reserved")
+	}
+}
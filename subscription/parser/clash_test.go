@@ -0,0 +1,401 @@
+package parser
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"github.com/stretchr/testify/require"
+)
+
+const clashMetaTestContent = `
+mixed-port: 7890
+proxies:
+  - name: "VLESS Reality"
+    type: vless
+    server: 203.0.113.10
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    network: tcp
+    tls: true
+    udp: true
+    flow: xtls-rprx-vision
+    servername: www.microsoft.com
+    reality-opts:
+      public-key: SbVKOEMjK0sIlbwg4akyBg5mL5KZwwB-ed4eEE7YnRc
+      short-id: 6ba85179e30d4fc2
+    client-fingerprint: safari
+  - name: "VLESS gRPC"
+    type: vless
+    server: grpc.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    udp: true
+    tls: true
+    network: grpc
+    servername: grpc.example.com
+    grpc-opts:
+      grpc-service-name: vless-grpc
+  - name: "Hysteria"
+    type: hysteria
+    server: hy.example.com
+    port: 8443
+    auth-str: secret
+    obfs: obfs-password
+    up: "30 Mbps"
+    down: 200
+    sni: hy.example.com
+    skip-cert-verify: true
+    alpn:
+      - h3
+  - name: "Hysteria2"
+    type: hysteria2
+    server: hy2.example.com
+    ports: 20000-30000
+    password: letmein
+    up: "50 Mbps"
+    down: "1 Gbps"
+    obfs: salamander
+    obfs-password: gawrgura
+    sni: hy2.example.com
+  - name: "TUIC"
+    type: tuic
+    server: tuic.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    password: p@ssword
+    heartbeat-interval: 10000
+    alpn: [h3]
+    reduce-rtt: true
+    udp-relay-mode: native
+    congestion-controller: bbr
+    sni: tuic.example.com
+  - name: "WireGuard"
+    type: wireguard
+    server: 162.159.192.1
+    port: 2408
+    ip: 172.16.0.2
+    ipv6: 2606:4700:110:8a36::2
+    private-key: eCtXsJZ27+4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU=
+    public-key: bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=
+    reserved: [209, 98, 59]
+    mtu: 1280
+    udp: true
+  - name: "Snell"
+    type: snell
+    server: snell.example.com
+    port: 44046
+    psk: yourpsk
+`
+
+func TestParseClashMetaSubscription(t *testing.T) {
+	t.Parallel()
+	outbounds, endpoints, err := ParseClashSubscription(context.Background(), clashMetaTestContent)
+	require.ErrorContains(t, err, "unsupported proxy type: Snell")
+	require.Equal(t, []option.Outbound{
+		{
+			Type: C.TypeVLESS,
+			Tag:  "VLESS Reality",
+			Options: &option.VLESSOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "203.0.113.10",
+					ServerPort: 443,
+				},
+				UUID: "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Flow: "xtls-rprx-vision",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "www.microsoft.com",
+						UTLS: &option.OutboundUTLSOptions{
+							Enabled:     true,
+							Fingerprint: "safari",
+						},
+						Reality: &option.OutboundRealityOptions{
+							Enabled:   true,
+							PublicKey: "SbVKOEMjK0sIlbwg4akyBg5mL5KZwwB-ed4eEE7YnRc",
+							ShortID:   "6ba85179e30d4fc2",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeVLESS,
+			Tag:  "VLESS gRPC",
+			Options: &option.VLESSOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "grpc.example.com",
+					ServerPort: 443,
+				},
+				UUID: "b831381d-6324-4d53-ad4f-8cda48b30811",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "grpc.example.com",
+					},
+				},
+				Transport: &option.V2RayTransportOptions{
+					Type: C.V2RayTransportTypeGRPC,
+					GRPCOptions: option.V2RayGRPCOptions{
+						ServiceName: "vless-grpc",
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeHysteria,
+			Tag:  "Hysteria",
+			Options: &option.HysteriaOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "hy.example.com",
+					ServerPort: 8443,
+				},
+				Up:         "30 Mbps",
+				DownMbps:   200,
+				Obfs:       "obfs-password",
+				AuthString: "secret",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "hy.example.com",
+						Insecure:   true,
+						ALPN:       []string{"h3"},
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeHysteria2,
+			Tag:  "Hysteria2",
+			Options: &option.Hysteria2OutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "hy2.example.com",
+					ServerPort: 20000,
+				},
+				UpMbps:   50,
+				DownMbps: 1000,
+				Obfs: &option.Hysteria2Obfs{
+					Type:     "salamander",
+					Password: "gawrgura",
+				},
+				Password: "letmein",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "hy2.example.com",
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeTUIC,
+			Tag:  "TUIC",
+			Options: &option.TUICOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "tuic.example.com",
+					ServerPort: 443,
+				},
+				UUID:              "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Password:          "p@ssword",
+				CongestionControl: "bbr",
+				UDPRelayMode:      "native",
+				ZeroRTTHandshake:  true,
+				Heartbeat:         badoption.Duration(10 * time.Second),
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "tuic.example.com",
+						ALPN:       []string{"h3"},
+					},
+				},
+			},
+		},
+	}, outbounds)
+	require.Equal(t, []option.Endpoint{
+		{
+			Type: C.TypeWireGuard,
+			Tag:  "WireGuard",
+			Options: &option.WireGuardEndpointOptions{
+				Address: []netip.Prefix{
+					netip.MustParsePrefix("172.16.0.2/32"),
+					netip.MustParsePrefix("2606:4700:110:8a36::2/128"),
+				},
+				PrivateKey: "eCtXsJZ27+4PbhDkHnB923tkUn2Gj59wZw5wFA75MnU=",
+				MTU:        1280,
+				Peers: []option.WireGuardPeer{{
+					Address:    "162.159.192.1",
+					Port:       2408,
+					PublicKey:  "bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=",
+					AllowedIPs: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")},
+					Reserved:   []uint8{209, 98, 59},
+				}},
+			},
+		},
+	}, endpoints)
+}
+
+func TestParseClashTLSProxies(t *testing.T) {
+	t.Parallel()
+	outbounds, _, err := ParseClashSubscription(context.Background(), `
+proxies:
+  - name: "HTTPS"
+    type: http
+    server: https.example.com
+    port: 443
+    username: user
+    password: pass
+    tls: true
+    sni: proxy.example.com
+    skip-cert-verify: true
+    fingerprint: chrome
+  - name: "SOCKS5 TLS"
+    type: socks5
+    server: socks.example.com
+    port: 1080
+    tls: true
+`)
+	require.ErrorContains(t, err, "TLS is not supported for SOCKS outbound")
+	require.Equal(t, []option.Outbound{
+		{
+			Type: C.TypeHTTP,
+			Tag:  "HTTPS",
+			Options: &option.HTTPOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "https.example.com",
+					ServerPort: 443,
+				},
+				Username: "user",
+				Password: "pass",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "proxy.example.com",
+						Insecure:   true,
+						UTLS: &option.OutboundUTLSOptions{
+							Enabled:     true,
+							Fingerprint: "chrome",
+						},
+					},
+				},
+			},
+		},
+	}, outbounds)
+}
+
+func TestParseClashSubscriptionSkipsBadProxies(t *testing.T) {
+	t.Parallel()
+	outbounds, _, err := ParseClashSubscription(context.Background(), `
+proxies:
+  - name: "Broken"
+    type: vmess
+    server: broken.example.com
+    port: 443
+    uuid: not-a-uuid
+    cipher: auto
+  - name: "Good"
+    type: ss
+    server: ss.example.com
+    port: 8388
+    cipher: aes-128-gcm
+    password: password
+    udp: true
+`)
+	require.ErrorContains(t, err, "skip proxy[0] Broken")
+	require.Len(t, outbounds, 1)
+	require.Equal(t, "Good", outbounds[0].Tag)
+}
+
+func TestParseClashHysteriaProtocol(t *testing.T) {
+	t.Parallel()
+	outbounds, _, err := ParseClashSubscription(context.Background(), `
+proxies:
+  - name: "FakeTCP"
+    type: hysteria
+    server: hysteria.example.com
+    port: 443
+    auth-str: password
+    up: 10
+    down: 50
+    protocol: faketcp
+  - name: "WeChat"
+    type: hysteria
+    server: hysteria.example.com
+    port: 443
+    auth-str: password
+    up: 10
+    down: 50
+    obfs-protocol: wechat-video
+  - name: "UDP"
+    type: hysteria
+    server: hysteria.example.com
+    port: 443
+    auth-str: password
+    up: 10
+    down: 50
+    obfs-protocol: udp
+`)
+	require.ErrorContains(t, err, "skip proxy[0] FakeTCP: unsupported hysteria protocol: faketcp")
+	require.ErrorContains(t, err, "skip proxy[1] WeChat: unsupported hysteria protocol: wechat-video")
+	require.Len(t, outbounds, 1)
+	require.Equal(t, "UDP", outbounds[0].Tag)
+}
+
+func TestParseClashTLSExtraOptions(t *testing.T) {
+	t.Parallel()
+	outbounds, _, err := ParseClashSubscription(context.Background(), `
+proxies:
+  - name: "Trojan Reality"
+    type: trojan
+    server: trojan.example.com
+    port: 443
+    password: password
+    sni: www.apple.com
+    client-fingerprint: ios
+    reality-opts:
+      public-key: SbVKOEMjK0sIlbwg4akyBg5mL5KZwwB-ed4eEE7YnRc
+  - name: "VMess ECH"
+    type: vmess
+    server: vmess.example.com
+    port: 443
+    uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+    alterId: 0
+    cipher: auto
+    tls: true
+    servername: vmess.example.com
+    fingerprint: 0a1b2c3d
+    ech-opts:
+      enable: true
+      config: AAECAwQFBgc=
+`)
+	require.ErrorContains(t, err, "proxy[1] VMess ECH: certificate fingerprint pinning is not supported, ignored")
+	skipped, warnings := SplitWarnings(err)
+	require.Empty(t, skipped)
+	require.Len(t, warnings, 1)
+	require.Len(t, outbounds, 2)
+	require.Equal(t, &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: "www.apple.com",
+		UTLS: &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: "ios",
+		},
+		Reality: &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: "SbVKOEMjK0sIlbwg4akyBg5mL5KZwwB-ed4eEE7YnRc",
+		},
+	}, outbounds[0].Options.(*option.TrojanOutboundOptions).TLS)
+	require.Equal(t, &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: "vmess.example.com",
+		ECH: &option.OutboundECHOptions{
+			Enabled: true,
+			Config:  []string{"-----BEGIN ECH CONFIGS-----\nAAECAwQFBgc=\n-----END ECH CONFIGS-----"},
+		},
+	}, outbounds[1].Options.(*option.VMessOutboundOptions).TLS)
+}
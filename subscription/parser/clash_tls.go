@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/Dreamacro/clash/common/structure"
+)
+
+// clashTLSExtraOptions holds the TLS fields Clash.Meta accepts on every TLS-capable proxy type.
+type clashTLSExtraOptions struct {
+	Fingerprint       string              `proxy:"fingerprint,omitempty"`
+	ClientFingerprint string              `proxy:"client-fingerprint,omitempty"`
+	RealityOpts       clashRealityOptions `proxy:"reality-opts,omitempty"`
+	ECHOpts           clashECHOptions     `proxy:"ech-opts,omitempty"`
+}
+
+type clashRealityOptions struct {
+	PublicKey string `proxy:"public-key"`
+	ShortID   string `proxy:"short-id,omitempty"`
+}
+
+type clashECHOptions struct {
+	Enable bool   `proxy:"enable,omitempty"`
+	Config string `proxy:"config,omitempty"`
+}
+
+func clashApplyOutboundTLSOptions(decoder *structure.Decoder, proxyMapping map[string]any, outbound option.Outbound, warn func(message ...any)) error {
+	tlsOptionsWrapper, isWrapper := outbound.Options.(option.OutboundTLSOptionsWrapper)
+	if !isWrapper || tlsOptionsWrapper.TakeOutboundTLSOptions() == nil {
+		return nil
+	}
+	var isQUIC bool
+	switch outbound.Type {
+	case C.TypeHysteria, C.TypeHysteria2, C.TypeTUIC:
+		isQUIC = true
+	}
+	return clashApplyTLSOptions(decoder, proxyMapping, tlsOptionsWrapper.TakeOutboundTLSOptions(), isQUIC, warn)
+}
+
+func clashApplyTLSOptions(decoder *structure.Decoder, proxyMapping map[string]any, options *option.OutboundTLSOptions, isQUIC bool, warn func(message ...any)) error {
+	extraOptions := &clashTLSExtraOptions{}
+	err := decoder.Decode(proxyMapping, extraOptions)
+	if err != nil {
+		return err
+	}
+	if extraOptions.RealityOpts.PublicKey != "" {
+		if isQUIC {
+			return E.New("REALITY is not supported for QUIC based protocols")
+		}
+		options.Enabled = true
+		options.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: extraOptions.RealityOpts.PublicKey,
+			ShortID:   extraOptions.RealityOpts.ShortID,
+		}
+	}
+	if !options.Enabled {
+		return nil
+	}
+	fingerprint := extraOptions.ClientFingerprint
+	if extraOptions.Fingerprint != "" {
+		if !clashIsUTLSFingerprint(extraOptions.Fingerprint) {
+			warn("certificate fingerprint pinning is not supported, ignored")
+		} else if fingerprint == "" {
+			fingerprint = extraOptions.Fingerprint
+		}
+	}
+	// REALITY is only available through uTLS
+	if fingerprint == "" && options.Reality != nil {
+		fingerprint = "chrome"
+	}
+	if fingerprint != "" && !isQUIC {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	if extraOptions.ECHOpts.Enable {
+		options.ECH = &option.OutboundECHOptions{
+			Enabled: true,
+		}
+		if extraOptions.ECHOpts.Config != "" {
+			echConfig, err := base64.StdEncoding.DecodeString(extraOptions.ECHOpts.Config)
+			if err != nil {
+				return E.Cause(err, "decode ECH config")
+			}
+			options.ECH.Config = []string{strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "ECH CONFIGS", Bytes: echConfig})))}
+		}
+	}
+	return nil
+}
+
+func clashIsUTLSFingerprint(fingerprint string) bool {
+	switch fingerprint {
+	case "chrome", "firefox", "edge", "safari", "360", "qq", "ios", "android", "random", "randomized":
+		return true
+	default:
+		return false
+	}
+}
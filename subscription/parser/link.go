@@ -1,10 +1,14 @@
 package parser
 
 import (
+	"net/url"
+	"strconv"
 	"strings"
 
+	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
 )
 
 func ParseSubscriptionLink(link string) (option.Outbound, error) {
@@ -16,7 +20,156 @@ func ParseSubscriptionLink(link string) (option.Outbound, error) {
 	switch scheme {
 	case "ss":
 		return ParseShadowsocksLink(link)
+	case "vmess":
+		return ParseVMessLink(link)
+	case "vless":
+		return ParseVLESSLink(link)
+	case "trojan":
+		return ParseTrojanLink(link)
+	case "hysteria2", "hy2":
+		return ParseHysteria2Link(link)
+	case "tuic":
+		return ParseTUICLink(link)
 	default:
 		return option.Outbound{}, E.New("unsupported scheme: ", scheme)
 	}
 }
+
+func linkTLSOptions(query url.Values) *option.OutboundTLSOptions {
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: query.Get("sni"),
+		ALPN:       linkStringList(query.Get("alpn")),
+		Insecure:   linkBool(query.Get("allowInsecure")) || linkBool(query.Get("insecure")),
+	}
+	if options.ServerName == "" {
+		options.ServerName = query.Get("peer")
+	}
+	if fingerprint := query.Get("fp"); fingerprint != "" {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	if query.Get("security") == "reality" {
+		options.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: query.Get("pbk"),
+			ShortID:   query.Get("sid"),
+		}
+		// REALITY is only available through uTLS
+		if options.UTLS == nil {
+			options.UTLS = &option.OutboundUTLSOptions{
+				Enabled:     true,
+				Fingerprint: "chrome",
+			}
+		}
+	}
+	return options
+}
+
+func linkTransport(query url.Values) (*option.V2RayTransportOptions, error) {
+	network := query.Get("type")
+	switch network {
+	case "", "tcp":
+		if query.Get("headerType") != "http" {
+			return nil, nil
+		}
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: linkStringList(query.Get("host")),
+				Path: query.Get("path"),
+			},
+		}, nil
+	case "h2", "http":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: linkStringList(query.Get("host")),
+				Path: query.Get("path"),
+			},
+		}, nil
+	case "ws":
+		var headers badoption.HTTPHeader
+		if host := query.Get("host"); host != "" {
+			headers = badoption.HTTPHeader{"Host": []string{host}}
+		}
+		path, maxEarlyData := linkWebsocketPath(query.Get("path"))
+		if earlyData, err := strconv.ParseUint(query.Get("ed"), 10, 32); err == nil {
+			maxEarlyData = uint32(earlyData)
+		}
+		options := option.V2RayWebsocketOptions{
+			Path:         path,
+			Headers:      headers,
+			MaxEarlyData: maxEarlyData,
+		}
+		if options.MaxEarlyData > 0 {
+			options.EarlyDataHeaderName = "Sec-WebSocket-Protocol"
+		}
+		return &option.V2RayTransportOptions{
+			Type:             C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: options,
+		}, nil
+	case "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: query.Get("serviceName"),
+			},
+		}, nil
+	case "httpupgrade":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTPUpgrade,
+			HTTPUpgradeOptions: option.V2RayHTTPUpgradeOptions{
+				Host: query.Get("host"),
+				Path: query.Get("path"),
+			},
+		}, nil
+	default:
+		return nil, E.New("unsupported transport: ", network)
+	}
+}
+
+// linkWebsocketPath extracts the Xray-style `?ed=2048` early data hint from a websocket path.
+func linkWebsocketPath(path string) (string, uint32) {
+	pathURL, err := url.Parse(path)
+	if err != nil {
+		return path, 0
+	}
+	earlyData := pathURL.Query().Get("ed")
+	if earlyData == "" {
+		return path, 0
+	}
+	maxEarlyData, err := strconv.ParseUint(earlyData, 10, 32)
+	if err != nil {
+		return path, 0
+	}
+	query := pathURL.Query()
+	query.Del("ed")
+	pathURL.RawQuery = query.Encode()
+	return pathURL.String(), uint32(maxEarlyData)
+}
+
+func linkStringList(value string) badoption.Listable[string] {
+	if value == "" {
+		return nil
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func linkBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// ParseHysteria2Link parses a Hysteria 2 link. Certificate pinning and port hopping
+// are not available in the sing-box outbound options, so links using them are returned
+// together with a Warning.
+func ParseHysteria2Link(link string) (option.Outbound, error) {
+	trimmedLink := hysteria2TrimPortHopping(link)
+	linkURL, err := url.Parse(trimmedLink)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	query := linkURL.Query()
+	var options option.Hysteria2OutboundOptions
+	options.ServerOptions.Server = linkURL.Hostname()
+	options.ServerOptions.ServerPort = portFromString(linkURL.Port())
+	if options.ServerOptions.ServerPort == 0 {
+		options.ServerOptions.ServerPort = 443
+	}
+	if linkURL.User != nil {
+		options.Password = linkURL.User.Username()
+		if password, loaded := linkURL.User.Password(); loaded {
+			options.Password += ":" + password
+		}
+	}
+	if obfs := query.Get("obfs"); obfs != "" {
+		if obfs != "salamander" {
+			return option.Outbound{}, E.New("unsupported obfs: ", obfs)
+		}
+		options.Obfs = &option.Hysteria2Obfs{
+			Type:     obfs,
+			Password: query.Get("obfs-password"),
+		}
+	}
+	options.TLS = &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: query.Get("sni"),
+		Insecure:   linkBool(query.Get("insecure")),
+		ALPN:       linkStringList(query.Get("alpn")),
+	}
+
+	var outbound option.Outbound
+	outbound.Type = C.TypeHysteria2
+	outbound.Tag = linkURL.Fragment
+	outbound.Options = &options
+	var warnings []error
+	if query.Get("pinSHA256") != "" {
+		warnings = append(warnings, NewWarning(outbound.Tag, ": certificate pinning (pinSHA256) is not supported, ignored"))
+	}
+	if trimmedLink != link || query.Get("mport") != "" {
+		warnings = append(warnings, NewWarning(outbound.Tag, ": port hopping is not supported, using port ", options.ServerPort))
+	}
+	return outbound, E.Errors(warnings...)
+}
+
+// hysteria2TrimPortHopping keeps only the first port of a multi-port host
+// such as `example.com:443,20000-30000`, which url.Parse rejects.
+// The remaining ports are dropped.
+func hysteria2TrimPortHopping(link string) string {
+	schemeIndex := strings.Index(link, "://")
+	if schemeIndex == -1 {
+		return link
+	}
+	hostStart := schemeIndex + 3
+	hostEnd := len(link)
+	if index := strings.IndexAny(link[hostStart:], "/?#"); index != -1 {
+		hostEnd = hostStart + index
+	}
+	host := link[hostStart:hostEnd]
+	if index := strings.LastIndex(host, "@"); index != -1 {
+		hostStart += index + 1
+		host = host[index+1:]
+	}
+	portIndex := strings.LastIndex(host, ":")
+	if portIndex == -1 || strings.HasSuffix(host, "]") {
+		return link
+	}
+	port := host[portIndex+1:]
+	if index := strings.IndexAny(port, ",-"); index != -1 {
+		port = port[:index]
+	}
+	return link[:hostStart] + host[:portIndex+1] + port + link[hostEnd:]
+}
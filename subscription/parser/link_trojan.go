@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"net/url"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func ParseTrojanLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	if linkURL.User == nil || linkURL.User.Username() == "" {
+		return option.Outbound{}, E.New("missing password")
+	}
+
+	query := linkURL.Query()
+	var options option.TrojanOutboundOptions
+	options.ServerOptions.Server = linkURL.Hostname()
+	options.ServerOptions.ServerPort = portFromString(linkURL.Port())
+	if options.ServerOptions.ServerPort == 0 {
+		options.ServerOptions.ServerPort = 443
+	}
+	options.Password = linkURL.User.Username()
+	switch security := query.Get("security"); security {
+	case "none":
+	case "", "tls", "reality":
+		options.TLS = linkTLSOptions(query)
+	default:
+		return option.Outbound{}, E.New("unsupported security: ", security)
+	}
+	options.Transport, err = linkTransport(query)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	var outbound option.Outbound
+	outbound.Type = C.TypeTrojan
+	outbound.Tag = linkURL.Fragment
+	outbound.Options = &options
+	return outbound, nil
+}
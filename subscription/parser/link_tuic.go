@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"net/url"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func ParseTUICLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	if linkURL.User == nil || linkURL.User.Username() == "" {
+		return option.Outbound{}, E.New("missing uuid")
+	}
+
+	query := linkURL.Query()
+	var options option.TUICOutboundOptions
+	options.ServerOptions.Server = linkURL.Hostname()
+	options.ServerOptions.ServerPort = portFromString(linkURL.Port())
+	options.UUID = linkURL.User.Username()
+	options.Password, _ = linkURL.User.Password()
+	options.CongestionControl = query.Get("congestion_control")
+	if options.CongestionControl == "" {
+		options.CongestionControl = query.Get("congestion-control")
+	}
+	options.UDPRelayMode = query.Get("udp_relay_mode")
+	if options.UDPRelayMode == "" {
+		options.UDPRelayMode = query.Get("udp-relay-mode")
+	}
+	options.ZeroRTTHandshake = linkBool(query.Get("reduce_rtt")) || linkBool(query.Get("zero_rtt_handshake"))
+	options.TLS = &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: query.Get("sni"),
+		Insecure:   linkBool(query.Get("allow_insecure")) || linkBool(query.Get("allowInsecure")) || linkBool(query.Get("insecure")),
+		DisableSNI: linkBool(query.Get("disable_sni")),
+		ALPN:       linkStringList(query.Get("alpn")),
+	}
+
+	var outbound option.Outbound
+	outbound.Type = C.TypeTUIC
+	outbound.Tag = linkURL.Fragment
+	outbound.Options = &options
+	return outbound, nil
+}
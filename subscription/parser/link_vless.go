@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"net/url"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func ParseVLESSLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	if linkURL.User == nil || linkURL.User.Username() == "" {
+		return option.Outbound{}, E.New("missing uuid")
+	}
+
+	query := linkURL.Query()
+	var options option.VLESSOutboundOptions
+	options.ServerOptions.Server = linkURL.Hostname()
+	options.ServerOptions.ServerPort = portFromString(linkURL.Port())
+	options.UUID = linkURL.User.Username()
+	options.Flow = query.Get("flow")
+	switch security := query.Get("security"); security {
+	case "", "none":
+	case "tls", "xtls", "reality":
+		options.TLS = linkTLSOptions(query)
+	default:
+		return option.Outbound{}, E.New("unsupported security: ", security)
+	}
+	options.Transport, err = linkTransport(query)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if packetEncoding := query.Get("packetEncoding"); packetEncoding != "" {
+		options.PacketEncoding = &packetEncoding
+	}
+
+	var outbound option.Outbound
+	outbound.Type = C.TypeVLESS
+	outbound.Tag = linkURL.Fragment
+	outbound.Options = &options
+	return outbound, nil
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+type VMessLinkDocument struct {
+	Version     vmessLinkNumber `json:"v"`
+	Remarks     string          `json:"ps"`
+	Address     string          `json:"add"`
+	Port        vmessLinkNumber `json:"port"`
+	ID          string          `json:"id"`
+	AlterID     vmessLinkNumber `json:"aid"`
+	Security    string          `json:"scy"`
+	Network     string          `json:"net"`
+	Type        string          `json:"type"`
+	Host        string          `json:"host"`
+	Path        string          `json:"path"`
+	TLS         string          `json:"tls"`
+	SNI         string          `json:"sni"`
+	ALPN        string          `json:"alpn"`
+	Fingerprint string          `json:"fp"`
+}
+
+// vmessLinkNumber accepts both JSON numbers and numeric strings, since
+// V2RayN-style links use either for `v`, `port` and `aid`.
+type vmessLinkNumber uint64
+
+func (n *vmessLinkNumber) UnmarshalJSON(content []byte) error {
+	numberString := strings.Trim(string(content), "\"")
+	if numberString == "" || numberString == "null" {
+		*n = 0
+		return nil
+	}
+	number, err := strconv.ParseUint(numberString, 10, 64)
+	if err != nil {
+		return E.Cause(err, "parse number: ", numberString)
+	}
+	*n = vmessLinkNumber(number)
+	return nil
+}
+
+func ParseVMessLink(link string) (option.Outbound, error) {
+	content, err := decodeBase64URLSafe(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode vmess link")
+	}
+	var document VMessLinkDocument
+	err = json.Unmarshal([]byte(content), &document)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse vmess link")
+	}
+	if document.Address == "" {
+		return option.Outbound{}, E.New("missing server address")
+	}
+	if document.ID == "" {
+		return option.Outbound{}, E.New("missing uuid")
+	}
+	if document.Port == 0 || document.Port > math.MaxUint16 {
+		return option.Outbound{}, E.New("invalid port: ", uint64(document.Port))
+	}
+
+	var options option.VMessOutboundOptions
+	options.ServerOptions.Server = document.Address
+	options.ServerOptions.ServerPort = uint16(document.Port)
+	options.UUID = document.ID
+	options.AlterId = int(document.AlterID)
+	options.Security = document.Security
+	if options.Security == "" {
+		options.Security = "auto"
+	}
+	if document.TLS == "tls" {
+		serverName := document.SNI
+		if serverName == "" {
+			serverName = document.Host
+		}
+		options.TLS = &option.OutboundTLSOptions{
+			Enabled:    true,
+			ServerName: serverName,
+			ALPN:       linkStringList(document.ALPN),
+		}
+		if document.Fingerprint != "" {
+			options.TLS.UTLS = &option.OutboundUTLSOptions{
+				Enabled:     true,
+				Fingerprint: document.Fingerprint,
+			}
+		}
+	}
+	options.Transport, err = vmessTransport(document)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+
+	var outbound option.Outbound
+	outbound.Type = C.TypeVMess
+	outbound.Tag = document.Remarks
+	outbound.Options = &options
+	return outbound, nil
+}
+
+func vmessTransport(document VMessLinkDocument) (*option.V2RayTransportOptions, error) {
+	query := make(url.Values)
+	query.Set("type", document.Network)
+	query.Set("headerType", document.Type)
+	query.Set("host", document.Host)
+	query.Set("path", document.Path)
+	query.Set("serviceName", document.Path)
+	return linkTransport(query)
+}
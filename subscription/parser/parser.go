@@ -2,26 +2,139 @@ package parser
 
 import (
 	"context"
+	"sync"
 
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 )
 
-var subscriptionParsers = []func(ctx context.Context, content string) ([]option.Outbound, error){
-	ParseBoxSubscription,
-	ParseClashSubscription,
-	ParseSIP008Subscription,
-	ParseRawSubscription,
+const (
+	FormatAuto        = "auto"
+	FormatSingBox     = "sing-box"
+	FormatClash       = "clash"
+	FormatSIP008      = "sip008"
+	FormatWireGuard   = "wireguard"
+	FormatSurge       = "surge"
+	FormatLoon        = "loon"
+	FormatQuantumultX = "quantumultx"
+	FormatLinks       = "links"
+)
+
+// Version is increased whenever parsers produce different outbounds from the same content,
+// so that cached subscriptions are parsed again at startup.
+const Version = 2
+
+// Parser converts subscription content into outbounds. A non-nil error together
+// with outbounds reports entries that had to be skipped.
+type Parser func(ctx context.Context, content string) ([]option.Outbound, error)
+
+// EndpointParser is a Parser for formats that may also carry sing-box endpoints.
+type EndpointParser func(ctx context.Context, content string) ([]option.Outbound, []option.Endpoint, error)
+
+type registeredParser struct {
+	name   string
+	parser EndpointParser
+}
+
+var (
+	parserAccess sync.RWMutex
+	// parsers in auto-detection order
+	registeredParsers []registeredParser
+	parserByFormat    = make(map[string]EndpointParser)
+)
+
+func init() {
+	RegisterEndpointParser(ParseBoxSubscription, FormatSingBox)
+	RegisterEndpointParser(ParseClashSubscription, FormatClash)
+	Register(ParseSIP008Subscription, FormatSIP008)
+	RegisterEndpointParser(ParseWireGuardSubscription, FormatWireGuard)
+	Register(ParseSurgeSubscription, FormatSurge, FormatLoon)
+	Register(ParseQuantumultXSubscription, FormatQuantumultX)
+	Register(ParseRawSubscription, FormatLinks)
+}
+
+// Register adds a parser under the given format names and appends it to the
+// auto-detection order. It panics if a format name is already taken.
+func Register(parser Parser, formats ...string) {
+	RegisterEndpointParser(func(ctx context.Context, content string) ([]option.Outbound, []option.Endpoint, error) {
+		outbounds, err := parser(ctx, content)
+		return outbounds, nil, err
+	}, formats...)
+}
+
+// RegisterEndpointParser is like Register for parsers that also return endpoints.
+func RegisterEndpointParser(parser EndpointParser, formats ...string) {
+	if len(formats) == 0 {
+		panic("register parser: missing format")
+	}
+	parserAccess.Lock()
+	defer parserAccess.Unlock()
+	for _, format := range formats {
+		if format == FormatAuto {
+			panic("register parser: reserved format: " + format)
+		}
+		if _, loaded := parserByFormat[format]; loaded {
+			panic("register parser: duplicate format: " + format)
+		}
+	}
+	for _, format := range formats {
+		parserByFormat[format] = parser
+	}
+	registeredParsers = append(registeredParsers, registeredParser{formats[0], parser})
+}
+
+// Lookup returns the parser registered for format.
+func Lookup(format string) (EndpointParser, bool) {
+	parserAccess.RLock()
+	defer parserAccess.RUnlock()
+	parser, loaded := parserByFormat[format]
+	return parser, loaded
+}
+
+// IsValidFormat reports whether format can be passed to ParseSubscriptionFormat.
+func IsValidFormat(format string) bool {
+	if format == "" || format == FormatAuto {
+		return true
+	}
+	_, loaded := Lookup(format)
+	return loaded
 }
 
-func ParseSubscription(ctx context.Context, content string) ([]option.Outbound, error) {
-	var pErr error
-	for _, parser := range subscriptionParsers {
-		servers, err := parser(ctx, content)
-		if len(servers) > 0 {
-			return servers, nil
+// ParseSubscription returns the servers from the first parser that recognizes the content.
+// A non-nil error together with servers reports entries the parser had to skip.
+func ParseSubscription(ctx context.Context, content string) ([]option.Outbound, []option.Endpoint, error) {
+	parserAccess.RLock()
+	parsers := registeredParsers
+	parserAccess.RUnlock()
+	var errors []error
+	for _, parser := range parsers {
+		servers, endpoints, err := parser.parser(ctx, content)
+		if len(servers) > 0 || len(endpoints) > 0 {
+			return servers, endpoints, err
 		}
-		pErr = E.Errors(pErr, err)
+		if err != nil {
+			errors = append(errors, E.Cause(err, parser.name))
+		}
+	}
+	if len(errors) == 0 {
+		return nil, nil, E.New("no servers found")
+	}
+	return nil, nil, E.Cause(E.Errors(errors...), "no servers found")
+}
+
+// ParseSubscriptionFormat parses content with the parser registered for format,
+// or detects the format if it is empty or auto.
+func ParseSubscriptionFormat(ctx context.Context, format string, content string) ([]option.Outbound, []option.Endpoint, error) {
+	if format == "" || format == FormatAuto {
+		return ParseSubscription(ctx, content)
+	}
+	parser, loaded := Lookup(format)
+	if !loaded {
+		return nil, nil, E.New("unknown subscription format: ", format)
+	}
+	servers, endpoints, err := parser(ctx, content)
+	if len(servers) == 0 && len(endpoints) == 0 && err == nil {
+		err = E.New("no servers found")
 	}
-	return nil, E.Cause(pErr, "no servers found")
+	return servers, endpoints, err
 }
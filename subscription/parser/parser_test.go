@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubscriptionFormat(t *testing.T) {
+	t.Parallel()
+	const content = "ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example"
+	outbounds, _, err := ParseSubscriptionFormat(context.Background(), FormatLinks, content)
+	require.NoError(t, err)
+	require.Len(t, outbounds, 1)
+	_, _, err = ParseSubscriptionFormat(context.Background(), FormatClash, content)
+	require.Error(t, err)
+	_, _, err = ParseSubscriptionFormat(context.Background(), "unknown", content)
+	require.ErrorContains(t, err, "unknown subscription format")
+	_, _, err = ParseSubscription(context.Background(), "{}")
+	require.ErrorContains(t, err, FormatSingBox+": ")
+	require.ErrorContains(t, err, FormatClash+": ")
+}
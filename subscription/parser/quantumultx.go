@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+// ParseQuantumultXSubscription parses Quantumult X server lists such as
+// `shadowsocks=host:port, method=aes-128-gcm, password=pwd, tag=name`,
+// either bare or inside a [server_local] section.
+func ParseQuantumultXSubscription(_ context.Context, content string) ([]option.Outbound, error) {
+	var (
+		outbounds []option.Outbound
+		warnings  []error
+	)
+	for lineIndex, line := range proxyListLines(content, "server_local") {
+		fields := splitProxyListLine(line)
+		proxyType, address, found := strings.Cut(fields[0], "=")
+		if !found {
+			continue
+		}
+		proxyType = strings.ToLower(strings.TrimSpace(proxyType))
+		params := make(map[string]string)
+		for _, field := range fields[1:] {
+			if key, value, found := strings.Cut(field, "="); found {
+				params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), "\"")
+			}
+		}
+		outbound, err := parseQuantumultXProxy(proxyType, strings.TrimSpace(address), params)
+		if err != nil {
+			warnings = append(warnings, E.Cause(err, "skip proxy[", lineIndex, "] ", params["tag"]))
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	if len(outbounds) > 0 {
+		return outbounds, E.Errors(warnings...)
+	}
+	if len(warnings) > 0 {
+		return nil, E.Cause(E.Errors(warnings...), "no servers found")
+	}
+	return nil, E.New("no servers found")
+}
+
+func parseQuantumultXProxy(proxyType string, address string, params map[string]string) (option.Outbound, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse server address")
+	}
+	serverOptions := option.ServerOptions{
+		Server:     host,
+		ServerPort: portFromString(port),
+	}
+	obfs := params["obfs"]
+	var outbound option.Outbound
+	outbound.Tag = params["tag"]
+	switch proxyType {
+	case "shadowsocks":
+		var (
+			plugin        string
+			pluginOptions string
+		)
+		switch obfs {
+		case "":
+		case "http", "tls":
+			plugin = "obfs-local"
+			pluginOptions = shadowsocksPluginOptionsBuilder{
+				"obfs":      obfs,
+				"obfs-host": surgeOptionalParam(params, "obfs-host"),
+				"obfs-uri":  surgeOptionalParam(params, "obfs-uri"),
+			}.Build()
+		case "ws", "wss":
+			var tls any
+			if obfs == "wss" {
+				tls = true
+			}
+			plugin = "v2ray-plugin"
+			pluginOptions = shadowsocksPluginOptionsBuilder{
+				"mode": "websocket",
+				"tls":  tls,
+				"host": surgeOptionalParam(params, "obfs-host"),
+				"path": surgeOptionalParam(params, "obfs-uri"),
+			}.Build()
+		default:
+			return option.Outbound{}, E.New("unsupported obfs: ", obfs)
+		}
+		outbound.Type = C.TypeShadowsocks
+		outbound.Options = &option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        params["method"],
+			Password:      params["password"],
+			Plugin:        plugin,
+			PluginOptions: pluginOptions,
+			Network:       clashNetworks(linkBool(params["udp-relay"])),
+		}
+	case "vmess":
+		security := params["method"]
+		if security == "" || security == "none" {
+			security = "auto"
+		}
+		outbound.Type = C.TypeVMess
+		outbound.Options = &option.VMessOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          params["password"],
+			Security:      security,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: quantumultXTLSOptions(params, false),
+			},
+			Transport: quantumultXTransport(params),
+		}
+	case "vless":
+		outbound.Type = C.TypeVLESS
+		outbound.Options = &option.VLESSOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          params["password"],
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: quantumultXTLSOptions(params, false),
+			},
+			Transport: quantumultXTransport(params),
+		}
+	case "trojan":
+		outbound.Type = C.TypeTrojan
+		outbound.Options = &option.TrojanOutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      params["password"],
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: quantumultXTLSOptions(params, true),
+			},
+			Transport: quantumultXTransport(params),
+			Network:   clashNetworks(linkBool(params["udp-relay"])),
+		}
+	case "http":
+		outbound.Type = C.TypeHTTP
+		outbound.Options = &option.HTTPOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      params["username"],
+			Password:      params["password"],
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: quantumultXTLSOptions(params, false),
+			},
+		}
+	case "socks5":
+		if quantumultXTLSOptions(params, false) != nil {
+			return option.Outbound{}, E.New("TLS is not supported for SOCKS outbound")
+		}
+		outbound.Type = C.TypeSOCKS
+		outbound.Options = &option.SOCKSOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      params["username"],
+			Password:      params["password"],
+			Network:       clashNetworks(linkBool(params["udp-relay"])),
+		}
+	default:
+		return option.Outbound{}, E.New("unsupported proxy type: ", proxyType)
+	}
+	return outbound, nil
+}
+
+func quantumultXTLSOptions(params map[string]string, enabled bool) *option.OutboundTLSOptions {
+	switch params["obfs"] {
+	case "over-tls", "wss":
+		enabled = true
+	}
+	if !enabled && !linkBool(params["over-tls"]) {
+		return nil
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: params["tls-host"],
+		ALPN:       linkStringList(params["tls-alpn"]),
+	}
+	if options.ServerName == "" {
+		options.ServerName = params["obfs-host"]
+	}
+	if verification, loaded := params["tls-verification"]; loaded {
+		options.Insecure = !linkBool(verification)
+	}
+	return options
+}
+
+func quantumultXTransport(params map[string]string) *option.V2RayTransportOptions {
+	switch params["obfs"] {
+	case "ws", "wss":
+		var headers badoption.HTTPHeader
+		if host := params["obfs-host"]; host != "" {
+			headers = badoption.HTTPHeader{"Host": []string{host}}
+		}
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path:    params["obfs-uri"],
+				Headers: headers,
+			},
+		}
+	case "http":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: linkStringList(params["obfs-host"]),
+				Path: params["obfs-uri"],
+			},
+		}
+	default:
+		return nil
+	}
+}
@@ -11,7 +11,7 @@ import (
 
 func ParseRawSubscription(_ context.Context, content string) ([]option.Outbound, error) {
 	if base64Content, err := decodeBase64URLSafe(content); err == nil {
-		servers, _ := parseRawSubscription(base64Content)
+		servers, err := parseRawSubscription(base64Content)
 		if len(servers) > 0 {
 			return servers, err
 		}
@@ -20,25 +20,34 @@ func ParseRawSubscription(_ context.Context, content string) ([]option.Outbound,
 }
 
 func parseRawSubscription(content string) ([]option.Outbound, error) {
-	var servers []option.Outbound
+	var (
+		servers  []option.Outbound
+		warnings []error
+	)
 	content = strings.ReplaceAll(content, "\r\n", "\n")
 	linkList := strings.Split(content, "\n")
 	for _, linkLine := range linkList {
-		if server, err := ParseSubscriptionLink(linkLine); err == nil {
-			servers = append(servers, server)
+		server, err := ParseSubscriptionLink(strings.TrimSpace(linkLine))
+		if err != nil && !IsWarning(err) {
+			continue
+		}
+		servers = append(servers, server)
+		if err != nil {
+			warnings = append(warnings, err)
 		}
 	}
 	if len(servers) == 0 {
 		return nil, E.New("no servers found")
 	}
-	return servers, nil
+	return servers, E.Errors(warnings...)
 }
 
 func decodeBase64URLSafe(content string) (string, error) {
+	content = strings.TrimSpace(content)
 	content = strings.ReplaceAll(content, " ", "-")
 	content = strings.ReplaceAll(content, "/", "_")
 	content = strings.ReplaceAll(content, "+", "-")
 	content = strings.ReplaceAll(content, "=", "")
-	result, err := base64.StdEncoding.DecodeString(content)
+	result, err := base64.RawURLEncoding.DecodeString(content)
 	return string(result), err
 }
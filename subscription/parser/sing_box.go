@@ -10,10 +10,10 @@ import (
 	"github.com/sagernet/sing/common/json"
 )
 
-func ParseBoxSubscription(ctx context.Context, content string) ([]option.Outbound, error) {
+func ParseBoxSubscription(ctx context.Context, content string) ([]option.Outbound, []option.Endpoint, error) {
 	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(content))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	options.Outbounds = common.Filter(options.Outbounds, func(it option.Outbound) bool {
 		switch it.Type {
@@ -23,8 +23,8 @@ func ParseBoxSubscription(ctx context.Context, content string) ([]option.Outboun
 			return true
 		}
 	})
-	if len(options.Outbounds) == 0 {
-		return nil, E.New("no servers found")
+	if len(options.Outbounds) == 0 && len(options.Endpoints) == 0 {
+		return nil, nil, E.New("no servers found")
 	}
-	return options.Outbounds, nil
+	return options.Outbounds, options.Endpoints, nil
 }
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBoxSubscriptionEndpoints(t *testing.T) {
+	t.Parallel()
+	ctx := box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+	outbounds, endpoints, err := ParseSubscription(ctx, `{
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {"type": "shadowsocks", "tag": "ss", "server": "example.com", "server_port": 8388, "method": "aes-128-gcm", "password": "pass"}
+  ],
+  "endpoints": [
+    {
+      "type": "wireguard",
+      "tag": "wg",
+      "address": ["10.0.0.2/32"],
+      "private_key": "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+      "peers": [
+        {"address": "example.com", "port": 51820, "public_key": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "allowed_ips": ["0.0.0.0/0"]}
+      ]
+    }
+  ]
+}`)
+	require.NoError(t, err)
+	require.Len(t, outbounds, 1)
+	require.Equal(t, "ss", outbounds[0].Tag)
+	require.Len(t, endpoints, 1)
+	require.Equal(t, C.TypeWireGuard, endpoints[0].Type)
+	require.Equal(t, "wg", endpoints[0].Tag)
+	endpointOptions, isWireGuard := endpoints[0].Options.(*option.WireGuardEndpointOptions)
+	require.True(t, isWireGuard)
+	require.Equal(t, "example.com", endpointOptions.Peers[0].Address)
+}
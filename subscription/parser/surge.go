@@ -0,0 +1,356 @@
+package parser
+
+import (
+	"context"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+// ParseSurgeSubscription parses Surge and Loon proxy lists, either as a complete
+// configuration with a [Proxy] section or as bare `name = type, server, port, ...` lines.
+func ParseSurgeSubscription(_ context.Context, content string) ([]option.Outbound, error) {
+	var (
+		outbounds []option.Outbound
+		warnings  []error
+	)
+	for lineIndex, line := range proxyListLines(content, "proxy") {
+		nameIndex := strings.Index(line, "=")
+		if nameIndex == -1 {
+			continue
+		}
+		name := strings.TrimSpace(line[:nameIndex])
+		fields := splitProxyListLine(line[nameIndex+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		outbound, err := parseSurgeProxy(name, fields)
+		if err != nil {
+			warnings = append(warnings, E.Cause(err, "skip proxy[", lineIndex, "] ", name))
+			continue
+		}
+		if outbound.Type == "" {
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	if len(outbounds) > 0 {
+		return outbounds, E.Errors(warnings...)
+	}
+	if len(warnings) > 0 {
+		return nil, E.Cause(E.Errors(warnings...), "no servers found")
+	}
+	return nil, E.New("no servers found")
+}
+
+func parseSurgeProxy(name string, fields []string) (option.Outbound, error) {
+	proxyType := strings.ToLower(strings.Trim(fields[0], "\""))
+	var (
+		positional []string
+		params     = make(map[string]string)
+	)
+	for _, field := range fields[1:] {
+		if key, value, found := strings.Cut(field, "="); found && !strings.HasPrefix(field, "\"") {
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), "\"")
+		} else {
+			positional = append(positional, strings.Trim(field, "\""))
+		}
+	}
+	// positional argument after server and port, used by Loon for credentials
+	argument := func(index int, keys ...string) string {
+		for _, key := range keys {
+			if value := params[key]; value != "" {
+				return value
+			}
+		}
+		if len(positional) > index+2 {
+			return positional[index+2]
+		}
+		return ""
+	}
+	switch proxyType {
+	case "direct", "reject", "reject-drop", "reject-tinygif", "reject-no-drop":
+		return option.Outbound{}, nil
+	}
+	if len(positional) < 2 {
+		return option.Outbound{}, E.New("missing server or port")
+	}
+	serverOptions := option.ServerOptions{
+		Server:     positional[0],
+		ServerPort: portFromString(positional[1]),
+	}
+	var outbound option.Outbound
+	outbound.Tag = name
+	switch proxyType {
+	case "ss", "shadowsocks":
+		obfs := params["obfs"]
+		if obfs == "" {
+			obfs = params["obfs-name"]
+		}
+		var (
+			plugin        string
+			pluginOptions string
+		)
+		if obfs != "" {
+			plugin = "obfs-local"
+			pluginOptions = shadowsocksPluginOptionsBuilder{
+				"obfs":      obfs,
+				"obfs-host": surgeOptionalParam(params, "obfs-host"),
+				"obfs-uri":  surgeOptionalParam(params, "obfs-uri"),
+			}.Build()
+		}
+		outbound.Type = C.TypeShadowsocks
+		outbound.Options = &option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        argument(0, "encrypt-method", "method"),
+			Password:      argument(1, "password"),
+			Plugin:        plugin,
+			PluginOptions: pluginOptions,
+			Network:       surgeNetworks(params),
+		}
+	case "vmess":
+		security := argument(0, "encrypt-method", "method")
+		if security == "" {
+			security = "auto"
+		}
+		outbound.Type = C.TypeVMess
+		outbound.Options = &option.VMessOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          argument(1, "username", "uuid"),
+			Security:      security,
+			AlterId:       int(portFromString(params["alterid"])),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, false),
+			},
+			Transport: surgeTransport(params),
+		}
+	case "vless":
+		outbound.Type = C.TypeVLESS
+		vlessOptions := &option.VLESSOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          argument(0, "username", "uuid"),
+			Flow:          params["flow"],
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, false),
+			},
+			Transport: surgeTransport(params),
+		}
+		if publicKey := params["public-key"]; publicKey != "" {
+			if vlessOptions.TLS == nil {
+				vlessOptions.TLS = &option.OutboundTLSOptions{Enabled: true}
+			}
+			vlessOptions.TLS.Reality = &option.OutboundRealityOptions{
+				Enabled:   true,
+				PublicKey: publicKey,
+				ShortID:   params["short-id"],
+			}
+			if vlessOptions.TLS.UTLS == nil {
+				vlessOptions.TLS.UTLS = &option.OutboundUTLSOptions{
+					Enabled:     true,
+					Fingerprint: "chrome",
+				}
+			}
+		}
+		outbound.Options = vlessOptions
+	case "trojan":
+		outbound.Type = C.TypeTrojan
+		outbound.Options = &option.TrojanOutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      argument(0, "password"),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, true),
+			},
+			Transport: surgeTransport(params),
+			Network:   surgeNetworks(params),
+		}
+	case "http", "https":
+		outbound.Type = C.TypeHTTP
+		outbound.Options = &option.HTTPOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      argument(0, "username"),
+			Password:      argument(1, "password"),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, proxyType == "https"),
+			},
+		}
+	case "socks5":
+		if surgeTLSOptions(params, false) != nil {
+			return option.Outbound{}, E.New("TLS is not supported for SOCKS outbound")
+		}
+		outbound.Type = C.TypeSOCKS
+		outbound.Options = &option.SOCKSOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      argument(0, "username"),
+			Password:      argument(1, "password"),
+			Network:       surgeNetworks(params),
+		}
+	case "hysteria2":
+		outbound.Type = C.TypeHysteria2
+		outbound.Options = &option.Hysteria2OutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      argument(0, "password"),
+			DownMbps:      clashBandwidthMbps(params["download-bandwidth"]),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, true),
+			},
+		}
+	case "tuic", "tuic-v5":
+		uuid := argument(0, "uuid")
+		if uuid == "" {
+			return option.Outbound{}, E.New("TUIC v4 (token authentication) is not supported")
+		}
+		outbound.Type = C.TypeTUIC
+		outbound.Options = &option.TUICOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          uuid,
+			Password:      argument(1, "password"),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: surgeTLSOptions(params, true),
+			},
+		}
+	default:
+		return option.Outbound{}, E.New("unsupported proxy type: ", proxyType)
+	}
+	return outbound, nil
+}
+
+func surgeOptionalParam(params map[string]string, key string) any {
+	if value := params[key]; value != "" {
+		return value
+	}
+	return nil
+}
+
+func surgeNetworks(params map[string]string) option.NetworkList {
+	return clashNetworks(linkBool(params["udp-relay"]) || linkBool(params["udp"]))
+}
+
+func surgeTLSOptions(params map[string]string, enabled bool) *option.OutboundTLSOptions {
+	if !enabled && !linkBool(params["tls"]) && !linkBool(params["over-tls"]) {
+		return nil
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: params["sni"],
+		Insecure:   linkBool(params["skip-cert-verify"]),
+		ALPN:       linkStringList(params["alpn"]),
+	}
+	if options.ServerName == "" {
+		options.ServerName = params["tls-name"]
+	}
+	if fingerprint := params["client-fingerprint"]; clashIsUTLSFingerprint(fingerprint) {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	return options
+}
+
+func surgeTransport(params map[string]string) *option.V2RayTransportOptions {
+	var host, path string
+	switch {
+	case linkBool(params["ws"]):
+		path = params["ws-path"]
+		for _, header := range strings.Split(params["ws-headers"], "|") {
+			if key, value, found := strings.Cut(header, ":"); found && strings.EqualFold(strings.TrimSpace(key), "host") {
+				host = strings.TrimSpace(value)
+			}
+		}
+	case params["transport"] == "ws":
+		path = params["path"]
+		host = params["host"]
+	case params["transport"] == "http":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: linkStringList(params["host"]),
+				Path: params["path"],
+			},
+		}
+	case params["transport"] == "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: params["grpc-service-name"],
+			},
+		}
+	default:
+		return nil
+	}
+	var headers badoption.HTTPHeader
+	if host != "" {
+		headers = badoption.HTTPHeader{"Host": []string{host}}
+	}
+	return &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeWebsocket,
+		WebsocketOptions: option.V2RayWebsocketOptions{
+			Path:    path,
+			Headers: headers,
+		},
+	}
+}
+
+// proxyListLines returns the non-empty, non-comment lines of the named INI section,
+// or every line if the content has no sections at all.
+func proxyListLines(content string, section string) []string {
+	var (
+		lines          []string
+		sectionLines   []string
+		hasSection     bool
+		currentSection string
+	)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			hasSection = true
+			currentSection = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		lines = append(lines, line)
+		if currentSection == section {
+			sectionLines = append(sectionLines, line)
+		}
+	}
+	if hasSection {
+		return sectionLines
+	}
+	return lines
+}
+
+// splitProxyListLine splits comma separated fields, keeping commas inside double quotes.
+// Quotes are preserved so callers can tell quoted values from `key=value` pairs.
+func splitProxyListLine(line string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+	)
+	appendField := func() {
+		fields = append(fields, strings.TrimSpace(current.String()))
+		current.Reset()
+	}
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ',' && !quoted:
+			appendField()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	appendField()
+	if len(fields) == 1 && fields[0] == "" {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"context"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSurgeSubscription(t *testing.T) {
+	t.Parallel()
+	outbounds, err := ParseSurgeSubscription(context.Background(), `
+[General]
+loglevel = notify
+
+[Proxy]
+DIRECT = direct
+HK Trojan = trojan, hk.example.com, 443, password=pass, sni=hk.example.com, skip-cert-verify=true, udp-relay=true
+JP SS = ss, jp.example.com, 8388, encrypt-method=aes-128-gcm, password=secret
+US VMess = vmess, us.example.com, 443, username=b831381d-6324-4d53-ad4f-8cda48b30811, ws=true, ws-path=/ws, ws-headers=Host:cdn.example.com, tls=true, vmess-aead=true
+SG Snell = snell, sg.example.com, 443, psk=secret
+
+[Proxy Group]
+Proxy = select, HK Trojan, JP SS
+`)
+	require.ErrorContains(t, err, "unsupported proxy type: snell")
+	require.Equal(t, []option.Outbound{
+		{
+			Type: C.TypeTrojan,
+			Tag:  "HK Trojan",
+			Options: &option.TrojanOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "hk.example.com",
+					ServerPort: 443,
+				},
+				Password: "pass",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "hk.example.com",
+						Insecure:   true,
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeShadowsocks,
+			Tag:  "JP SS",
+			Options: &option.ShadowsocksOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "jp.example.com",
+					ServerPort: 8388,
+				},
+				Method:   "aes-128-gcm",
+				Password: "secret",
+				Network:  N.NetworkTCP,
+			},
+		},
+		{
+			Type: C.TypeVMess,
+			Tag:  "US VMess",
+			Options: &option.VMessOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "us.example.com",
+					ServerPort: 443,
+				},
+				UUID:     "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Security: "auto",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled: true,
+					},
+				},
+				Transport: &option.V2RayTransportOptions{
+					Type: C.V2RayTransportTypeWebsocket,
+					WebsocketOptions: option.V2RayWebsocketOptions{
+						Path:    "/ws",
+						Headers: badoption.HTTPHeader{"Host": []string{"cdn.example.com"}},
+					},
+				},
+			},
+		},
+	}, outbounds)
+}
+
+func TestParseLoonSubscription(t *testing.T) {
+	t.Parallel()
+	outbounds, err := ParseSurgeSubscription(context.Background(), `
+Loon SS = Shadowsocks,ss.example.com,8388,chacha20-ietf-poly1305,"pass,word",udp=true
+Loon Trojan = trojan,trojan.example.com,443,"password",tls-name=trojan.example.com,skip-cert-verify=false
+`)
+	require.NoError(t, err)
+	require.Equal(t, []option.Outbound{
+		{
+			Type: C.TypeShadowsocks,
+			Tag:  "Loon SS",
+			Options: &option.ShadowsocksOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "ss.example.com",
+					ServerPort: 8388,
+				},
+				Method:   "chacha20-ietf-poly1305",
+				Password: "pass,word",
+			},
+		},
+		{
+			Type: C.TypeTrojan,
+			Tag:  "Loon Trojan",
+			Options: &option.TrojanOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "trojan.example.com",
+					ServerPort: 443,
+				},
+				Password: "password",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "trojan.example.com",
+					},
+				},
+				Network: N.NetworkTCP,
+			},
+		},
+	}, outbounds)
+}
+
+func TestParseQuantumultXSubscription(t *testing.T) {
+	t.Parallel()
+	outbounds, err := ParseQuantumultXSubscription(context.Background(), `
+shadowsocks=ss.example.com:443, method=aes-256-gcm, password=pwd, fast-open=false, udp-relay=true, tag=QX SS
+vmess=vmess.example.com:443, method=chacha20-ietf-poly1305, password=b831381d-6324-4d53-ad4f-8cda48b30811, obfs=wss, obfs-host=cdn.example.com, obfs-uri=/ws, tls-verification=true, tag=QX VMess
+trojan=[2001:db8::1]:443, password=pwd, over-tls=true, tls-host=trojan.example.com, tls-verification=false, tag=QX Trojan
+`)
+	require.NoError(t, err)
+	require.Equal(t, []option.Outbound{
+		{
+			Type: C.TypeShadowsocks,
+			Tag:  "QX SS",
+			Options: &option.ShadowsocksOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "ss.example.com",
+					ServerPort: 443,
+				},
+				Method:   "aes-256-gcm",
+				Password: "pwd",
+			},
+		},
+		{
+			Type: C.TypeVMess,
+			Tag:  "QX VMess",
+			Options: &option.VMessOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "vmess.example.com",
+					ServerPort: 443,
+				},
+				UUID:     "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Security: "chacha20-ietf-poly1305",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "cdn.example.com",
+					},
+				},
+				Transport: &option.V2RayTransportOptions{
+					Type: C.V2RayTransportTypeWebsocket,
+					WebsocketOptions: option.V2RayWebsocketOptions{
+						Path:    "/ws",
+						Headers: badoption.HTTPHeader{"Host": []string{"cdn.example.com"}},
+					},
+				},
+			},
+		},
+		{
+			Type: C.TypeTrojan,
+			Tag:  "QX Trojan",
+			Options: &option.TrojanOutboundOptions{
+				ServerOptions: option.ServerOptions{
+					Server:     "2001:db8::1",
+					ServerPort: 443,
+				},
+				Password: "pwd",
+				OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "trojan.example.com",
+						Insecure:   true,
+					},
+				},
+				Network: N.NetworkTCP,
+			},
+		},
+	}, outbounds)
+}
+
+func TestParseProxyListNoServers(t *testing.T) {
+	t.Parallel()
+	_, err := ParseSurgeSubscription(context.Background(), "[Proxy]\nBroken = ss, example.com\n")
+	require.ErrorContains(t, err, "no servers found: skip proxy[0] Broken")
+	_, err = ParseQuantumultXSubscription(context.Background(), "shadowsocks=example.com, method=aes-128-gcm, tag=Broken\n")
+	require.ErrorContains(t, err, "no servers found: skip proxy[0] Broken")
+}
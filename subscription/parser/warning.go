@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"errors"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Warning reports an option dropped from a server that was otherwise converted.
+// Unlike skipped servers, warnings do not fail strict subscriptions.
+type Warning struct {
+	error
+}
+
+func (w *Warning) Unwrap() error {
+	return w.error
+}
+
+func NewWarning(message ...any) error {
+	return &Warning{E.New(message...)}
+}
+
+func IsWarning(err error) bool {
+	var warning *Warning
+	return errors.As(err, &warning)
+}
+
+// SplitWarnings separates the error returned together with servers into skipped entries and warnings.
+func SplitWarnings(err error) (skipped []error, warnings []error) {
+	for _, it := range E.Expand(err) {
+		if IsWarning(it) {
+			warnings = append(warnings, it)
+		} else {
+			skipped = append(skipped, it)
+		}
+	}
+	return
+}
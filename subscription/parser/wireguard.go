@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type wireGuardConfigSection struct {
+	name   string
+	values map[string]string
+}
+
+// ParseWireGuardSubscription parses a wg-quick style configuration file and
+// returns one WireGuard endpoint per [Peer], sharing the [Interface] settings.
+func ParseWireGuardSubscription(_ context.Context, content string) ([]option.Outbound, []option.Endpoint, error) {
+	var (
+		interfaceSection *wireGuardConfigSection
+		peerSections     []*wireGuardConfigSection
+		currentSection   *wireGuardConfigSection
+	)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			// `# Name = ...` is commonly used to label peers
+			if currentSection != nil {
+				key, value, found := strings.Cut(strings.TrimLeft(line, "#; "), "=")
+				if found && strings.EqualFold(strings.TrimSpace(key), "name") {
+					currentSection.name = strings.TrimSpace(value)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = &wireGuardConfigSection{values: make(map[string]string)}
+			switch strings.ToLower(strings.TrimSpace(line[1 : len(line)-1])) {
+			case "interface":
+				if interfaceSection != nil {
+					return nil, nil, E.New("duplicate [Interface] section")
+				}
+				interfaceSection = currentSection
+			case "peer":
+				peerSections = append(peerSections, currentSection)
+			default:
+				return nil, nil, E.New("unknown section: ", line)
+			}
+			continue
+		}
+		if currentSection == nil {
+			return nil, nil, E.New("not a WireGuard configuration")
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, nil, E.New("invalid line: ", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		// keys such as Address and AllowedIPs may be repeated
+		if previous, loaded := currentSection.values[key]; loaded {
+			value = previous + "," + value
+		}
+		currentSection.values[key] = value
+	}
+	if interfaceSection == nil {
+		return nil, nil, E.New("missing [Interface] section")
+	}
+	if len(peerSections) == 0 {
+		return nil, nil, E.New("no servers found")
+	}
+	privateKey := interfaceSection.values["privatekey"]
+	if privateKey == "" {
+		return nil, nil, E.New("missing PrivateKey in [Interface]")
+	}
+	localAddress, err := wireGuardPrefixes(interfaceSection.values["address"])
+	if err != nil {
+		return nil, nil, E.Cause(err, "parse Address")
+	}
+	var mtu uint64
+	if mtuString := interfaceSection.values["mtu"]; mtuString != "" {
+		mtu, err = strconv.ParseUint(mtuString, 10, 32)
+		if err != nil {
+			return nil, nil, E.Cause(err, "parse MTU")
+		}
+	}
+	var (
+		endpoints []option.Endpoint
+		warnings  []error
+	)
+	for peerIndex, peerSection := range peerSections {
+		peer, err := parseWireGuardPeer(peerSection.values, interfaceSection.values["reserved"])
+		if err != nil {
+			warnings = append(warnings, E.Cause(err, "skip peer[", peerIndex, "]"))
+			continue
+		}
+		tag := peerSection.name
+		if tag == "" {
+			tag = peerSection.values["endpoint"]
+		}
+		endpoints = append(endpoints, option.Endpoint{
+			Type: C.TypeWireGuard,
+			Tag:  tag,
+			Options: &option.WireGuardEndpointOptions{
+				Address:    localAddress,
+				PrivateKey: privateKey,
+				MTU:        uint32(mtu),
+				Peers:      []option.WireGuardPeer{peer},
+			},
+		})
+	}
+	if len(endpoints) > 0 {
+		return nil, endpoints, E.Errors(warnings...)
+	}
+	return nil, nil, E.Cause(E.Errors(warnings...), "no servers found")
+}
+
+func parseWireGuardPeer(values map[string]string, interfaceReserved string) (option.WireGuardPeer, error) {
+	endpoint := values["endpoint"]
+	if endpoint == "" {
+		return option.WireGuardPeer{}, E.New("missing Endpoint")
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return option.WireGuardPeer{}, E.Cause(err, "parse Endpoint")
+	}
+	publicKey := values["publickey"]
+	if publicKey == "" {
+		return option.WireGuardPeer{}, E.New("missing PublicKey")
+	}
+	allowedIPs, err := wireGuardPrefixes(values["allowedips"])
+	if err != nil {
+		return option.WireGuardPeer{}, E.Cause(err, "parse AllowedIPs")
+	}
+	reservedString := values["reserved"]
+	if reservedString == "" {
+		reservedString = interfaceReserved
+	}
+	var reserved []uint8
+	if reservedString != "" {
+		reserved, err = clashWireGuardReserved(reservedString)
+		if err != nil {
+			return option.WireGuardPeer{}, err
+		}
+	}
+	return option.WireGuardPeer{
+		Address:      host,
+		Port:         portFromString(port),
+		PublicKey:    publicKey,
+		PreSharedKey: values["presharedkey"],
+		AllowedIPs:   wireGuardAllowedIPs(allowedIPs),
+		Reserved:     reserved,
+	}, nil
+}
+
+func wireGuardPrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		prefix, err := clashPrefix(address)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// wireGuardAllowedIPs routes all traffic through the peer if no allowed IPs are set,
+// since endpoint peers drop traffic outside their allowed IPs.
+func wireGuardAllowedIPs(prefixes []netip.Prefix) []netip.Prefix {
+	if len(prefixes) > 0 {
+		return prefixes
+	}
+	return []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+}
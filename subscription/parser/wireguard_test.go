@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWireGuardSubscription(t *testing.T) {
+	t.Parallel()
+	outbounds, endpoints, err := ParseSubscription(context.Background(), `
+[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.0.0.2/32, fd00::2/128
+DNS = 1.1.1.1
+MTU = 1280
+
+[Peer]
+# Name = WARP
+PublicKey = bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=
+PresharedKey = /UwcSPg38hW/D9Y3tcS1FOV0K1wuURMbS0sesJEP5ak=
+AllowedIPs = 0.0.0.0/0, ::/0
+Endpoint = engage.cloudflareclient.com:2408
+Reserved = 1, 2, 3
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+AllowedIPs = 192.168.1.0/24
+Endpoint = [2001:db8::1]:51820
+`)
+	require.NoError(t, err)
+	localAddress := []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32"), netip.MustParsePrefix("fd00::2/128")}
+	require.Empty(t, outbounds)
+	require.Equal(t, []option.Endpoint{
+		{
+			Type: C.TypeWireGuard,
+			Tag:  "WARP",
+			Options: &option.WireGuardEndpointOptions{
+				Address:    localAddress,
+				PrivateKey: "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+				MTU:        1280,
+				Peers: []option.WireGuardPeer{{
+					Address:      "engage.cloudflareclient.com",
+					Port:         2408,
+					PublicKey:    "bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=",
+					PreSharedKey: "/UwcSPg38hW/D9Y3tcS1FOV0K1wuURMbS0sesJEP5ak=",
+					AllowedIPs:   []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")},
+					Reserved:     []uint8{1, 2, 3},
+				}},
+			},
+		},
+		{
+			Type: C.TypeWireGuard,
+			Tag:  "[2001:db8::1]:51820",
+			Options: &option.WireGuardEndpointOptions{
+				Address:    localAddress,
+				PrivateKey: "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+				MTU:        1280,
+				Peers: []option.WireGuardPeer{{
+					Address:    "2001:db8::1",
+					Port:       51820,
+					PublicKey:  "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+					AllowedIPs: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+				}},
+			},
+		},
+	}, endpoints)
+}
@@ -0,0 +1,69 @@
+package subscription
+
+import (
+	"context"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+func parsePatch(content json.RawMessage) (map[string]any, error) {
+	var patch any
+	err := json.Unmarshal(content, &patch)
+	if err != nil {
+		return nil, err
+	}
+	patchObject, isObject := patch.(map[string]any)
+	if !isObject {
+		return nil, E.New("patch must be a JSON object")
+	}
+	for _, key := range []string{"tag", "type"} {
+		if _, loaded := patchObject[key]; loaded {
+			return nil, E.New("patching `", key, "` is not allowed")
+		}
+	}
+	return patchObject, nil
+}
+
+// applyPatch applies a JSON merge patch (RFC 7386) to an outbound or endpoint.
+func applyPatch[T any](ctx context.Context, options T, patch map[string]any) (T, error) {
+	var patched T
+	// options types implement MarshalJSONContext on pointers
+	content, err := json.MarshalContext(ctx, &options)
+	if err != nil {
+		return patched, err
+	}
+	var target any
+	err = json.Unmarshal(content, &target)
+	if err != nil {
+		return patched, err
+	}
+	content, err = json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return patched, err
+	}
+	err = json.UnmarshalContext(ctx, content, &patched)
+	if err != nil {
+		return patched, err
+	}
+	return patched, nil
+}
+
+func mergePatch(target any, patch any) any {
+	patchObject, isObject := patch.(map[string]any)
+	if !isObject {
+		return patch
+	}
+	targetObject, isObject := target.(map[string]any)
+	if !isObject {
+		targetObject = make(map[string]any)
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -1,6 +1,7 @@
 package subscription
 
 import (
+	"context"
 	"regexp"
 	"strings"
 
@@ -12,9 +13,13 @@ import (
 
 type ProcessOptions struct {
 	option.OutboundProcessOptions
-	filter  []*regexp.Regexp
-	exclude []*regexp.Regexp
-	rename  []*Rename
+	ctx            context.Context
+	filter         []*regexp.Regexp
+	exclude        []*regexp.Regexp
+	rename         []*Rename
+	filterCountry  []string
+	excludeCountry []string
+	patch          map[string]any
 }
 
 type Rename struct {
@@ -22,7 +27,7 @@ type Rename struct {
 	To   string
 }
 
-func NewProcessOptions(options option.OutboundProcessOptions) (*ProcessOptions, error) {
+func NewProcessOptions(ctx context.Context, options option.OutboundProcessOptions) (*ProcessOptions, error) {
 	var (
 		filter  []*regexp.Regexp
 		exclude []*regexp.Regexp
@@ -54,70 +59,51 @@ func NewProcessOptions(options option.OutboundProcessOptions) (*ProcessOptions,
 			})
 		}
 	}
+	var patch map[string]any
+	if len(options.Patch) > 0 {
+		var err error
+		patch, err = parsePatch(options.Patch)
+		if err != nil {
+			return nil, E.Cause(err, "parse patch")
+		}
+	}
 	return &ProcessOptions{
 		OutboundProcessOptions: options,
+		ctx:                    ctx,
 		filter:                 filter,
 		exclude:                exclude,
 		rename:                 rename,
+		filterCountry:          common.Map(options.FilterCountry, strings.ToUpper),
+		excludeCountry:         common.Map(options.ExcludeCountry, strings.ToUpper),
+		patch:                  patch,
 	}, nil
 }
 
-func (o *ProcessOptions) Process(outbounds []boxOption.Outbound) []boxOption.Outbound {
+// NeedCountry reports whether the process matches servers by country and requires a CountryLookup.
+func (o *ProcessOptions) NeedCountry() bool {
+	return len(o.filterCountry) > 0 || len(o.excludeCountry) > 0
+}
+
+// Process applies the process to matched outbounds and endpoints.
+// lookupCountry is only used by country filters and may be nil otherwise.
+// The returned error reports outbounds that failed to patch, which are kept unpatched.
+func (o *ProcessOptions) Process(outbounds []boxOption.Outbound, endpoints []boxOption.Endpoint, lookupCountry CountryLookup) ([]boxOption.Outbound, []boxOption.Endpoint, error) {
 	newOutbounds := make([]boxOption.Outbound, 0, len(outbounds))
 	renameResult := make(map[string]string)
+	var patchErrors []error
 	for _, outbound := range outbounds {
-		var inProcess bool
-		if len(o.filter) == 0 && len(o.FilterType) == 0 && len(o.exclude) == 0 && len(o.ExcludeType) == 0 {
-			inProcess = true
-		} else {
-			if len(o.filter) > 0 {
-				if common.Any(o.filter, func(it *regexp.Regexp) bool {
-					return it.MatchString(outbound.Tag)
-				}) {
-					inProcess = true
-				}
-			}
-			if !inProcess && len(o.FilterType) > 0 {
-				if common.Contains(o.FilterType, outbound.Type) {
-					inProcess = true
-				}
-			}
-			if !inProcess && len(o.exclude) > 0 {
-				if !common.Any(o.exclude, func(it *regexp.Regexp) bool {
-					return it.MatchString(outbound.Tag)
-				}) {
-					inProcess = true
-				}
-			}
-			if !inProcess && len(o.ExcludeType) > 0 {
-				if !common.Contains(o.ExcludeType, outbound.Type) {
-					inProcess = true
-				}
-			}
+		var country string
+		if lookupCountry != nil && o.NeedCountry() {
+			country = lookupCountry(outbound)
 		}
-		if o.Invert {
-			inProcess = !inProcess
-		}
-		if !inProcess {
+		if !o.match(outbound.Tag, outbound.Type, country) {
 			newOutbounds = append(newOutbounds, outbound)
 			continue
 		}
 		if o.Remove {
 			continue
 		}
-		originTag := outbound.Tag
-		if len(o.rename) > 0 {
-			for _, rename := range o.rename {
-				outbound.Tag = rename.From.ReplaceAllString(outbound.Tag, rename.To)
-			}
-		}
-		if o.RemoveEmoji {
-			outbound.Tag = removeEmojis(outbound.Tag)
-		}
-		outbound.Tag = strings.TrimSpace(outbound.Tag)
-		if originTag != outbound.Tag {
-			renameResult[originTag] = outbound.Tag
-		}
+		outbound.Tag = o.renameTag(outbound.Tag, renameResult)
 		if o.RewriteMultiplex != nil {
 			switch outboundOptions := outbound.Options.(type) {
 			case *boxOption.ShadowsocksOutboundOptions:
@@ -130,26 +116,125 @@ func (o *ProcessOptions) Process(outbounds []boxOption.Outbound) []boxOption.Out
 				outboundOptions.Multiplex = o.RewriteMultiplex
 			}
 		}
+		if o.patch != nil {
+			patchedOutbound, err := applyPatch(o.ctx, outbound, o.patch)
+			if err != nil {
+				patchErrors = append(patchErrors, E.Cause(err, "patch outbound ", outbound.Tag))
+			} else {
+				outbound = patchedOutbound
+			}
+		}
 		newOutbounds = append(newOutbounds, outbound)
 	}
+	var newEndpoints []boxOption.Endpoint
+	for _, endpoint := range endpoints {
+		if !o.match(endpoint.Tag, endpoint.Type, "") {
+			newEndpoints = append(newEndpoints, endpoint)
+			continue
+		}
+		if o.Remove {
+			continue
+		}
+		endpoint.Tag = o.renameTag(endpoint.Tag, renameResult)
+		if o.patch != nil {
+			patchedEndpoint, err := applyPatch(o.ctx, endpoint, o.patch)
+			if err != nil {
+				patchErrors = append(patchErrors, E.Cause(err, "patch endpoint ", endpoint.Tag))
+			} else {
+				endpoint = patchedEndpoint
+			}
+		}
+		newEndpoints = append(newEndpoints, endpoint)
+	}
 	if len(renameResult) > 0 {
-		for i, outbound := range newOutbounds {
-			if dialerOptionsWrapper, containsDialerOptions := outbound.Options.(boxOption.DialerOptionsWrapper); containsDialerOptions {
-				dialerOptions := dialerOptionsWrapper.TakeDialerOptions()
-				if dialerOptions.Detour == "" {
-					continue
-				}
-				newTag, loaded := renameResult[dialerOptions.Detour]
-				if !loaded {
-					continue
-				}
-				dialerOptions.Detour = newTag
-				dialerOptionsWrapper.ReplaceDialerOptions(dialerOptions)
-				newOutbounds[i] = outbound
+		for _, outbound := range newOutbounds {
+			renameDetour(outbound.Options, renameResult)
+		}
+		for _, endpoint := range newEndpoints {
+			renameDetour(endpoint.Options, renameResult)
+		}
+	}
+	return newOutbounds, newEndpoints, E.Errors(patchErrors...)
+}
+
+func (o *ProcessOptions) match(tag string, outboundType string, country string) bool {
+	var inProcess bool
+	if len(o.filter) == 0 && len(o.FilterType) == 0 && len(o.exclude) == 0 && len(o.ExcludeType) == 0 && !o.NeedCountry() {
+		inProcess = true
+	} else {
+		if len(o.filter) > 0 {
+			if common.Any(o.filter, func(it *regexp.Regexp) bool {
+				return it.MatchString(tag)
+			}) {
+				inProcess = true
+			}
+		}
+		if !inProcess && len(o.FilterType) > 0 {
+			if common.Contains(o.FilterType, outboundType) {
+				inProcess = true
+			}
+		}
+		if !inProcess && len(o.exclude) > 0 {
+			if !common.Any(o.exclude, func(it *regexp.Regexp) bool {
+				return it.MatchString(tag)
+			}) {
+				inProcess = true
+			}
+		}
+		if !inProcess && len(o.ExcludeType) > 0 {
+			if !common.Contains(o.ExcludeType, outboundType) {
+				inProcess = true
+			}
+		}
+		if !inProcess && len(o.filterCountry) > 0 {
+			if country != "" && common.Contains(o.filterCountry, country) {
+				inProcess = true
+			}
+		}
+		if !inProcess && len(o.excludeCountry) > 0 {
+			if country != "" && !common.Contains(o.excludeCountry, country) {
+				inProcess = true
 			}
 		}
 	}
-	return newOutbounds
+	if o.Invert {
+		inProcess = !inProcess
+	}
+	return inProcess
+}
+
+func (o *ProcessOptions) renameTag(tag string, renameResult map[string]string) string {
+	originTag := tag
+	if len(o.rename) > 0 {
+		for _, rename := range o.rename {
+			tag = rename.From.ReplaceAllString(tag, rename.To)
+		}
+	}
+	if o.RemoveEmoji {
+		tag = removeEmojis(tag)
+	}
+	tag = strings.TrimSpace(tag)
+	if originTag != tag {
+		renameResult[originTag] = tag
+	}
+	return tag
+}
+
+func renameDetour(options any, renameResult map[string]string) {
+	dialerOptionsWrapper, containsDialerOptions := options.(boxOption.DialerOptionsWrapper)
+	if !containsDialerOptions {
+		return
+	}
+	dialerOptions := dialerOptionsWrapper.TakeDialerOptions()
+	if dialerOptions.Detour == "" {
+		return
+	}
+	newTag, loaded := renameResult[dialerOptions.Detour]
+	if !loaded {
+		return
+	}
+	dialerOptions.Detour = newTag
+	dialerOptionsWrapper.ReplaceDialerOptions(dialerOptions)
 }
 
 func removeEmojis(s string) string {
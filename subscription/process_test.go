@@ -0,0 +1,104 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	C "github.com/sagernet/sing-box/constant"
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessCountry(t *testing.T) {
+	t.Parallel()
+	newServer := func(tag string, server string) boxOption.Outbound {
+		return boxOption.Outbound{
+			Type: C.TypeShadowsocks,
+			Tag:  tag,
+			Options: &boxOption.ShadowsocksOutboundOptions{
+				ServerOptions: boxOption.ServerOptions{Server: server, ServerPort: 443},
+			},
+		}
+	}
+	servers := []boxOption.Outbound{
+		newServer("a", "1.1.1.1"),
+		newServer("b", "2.2.2.2"),
+		newServer("c", "unresolved.example.com"),
+	}
+	countries := map[string]string{
+		"1.1.1.1": "HK",
+		"2.2.2.2": "JP",
+	}
+	lookupCountry := func(server boxOption.Outbound) string {
+		return countries[serverAddress(server)]
+	}
+	for _, testCase := range []struct {
+		options  option.OutboundProcessOptions
+		expected []string
+	}{
+		{option.OutboundProcessOptions{FilterCountry: []string{"hk"}, Invert: true, Remove: true}, []string{"a"}},
+		{option.OutboundProcessOptions{ExcludeCountry: []string{"HK"}, Invert: true, Remove: true}, []string{"b"}},
+		{option.OutboundProcessOptions{FilterCountry: []string{"JP"}, Remove: true}, []string{"a", "c"}},
+	} {
+		processOptions, err := NewProcessOptions(context.Background(), testCase.options)
+		require.NoError(t, err)
+		require.True(t, processOptions.NeedCountry())
+		processed, _, err := processOptions.Process(servers, nil, lookupCountry)
+		require.NoError(t, err)
+		require.Equal(t, testCase.expected, common.Map(processed, func(it boxOption.Outbound) string {
+			return it.Tag
+		}))
+	}
+}
+
+func TestProcessPatch(t *testing.T) {
+	t.Parallel()
+	trojanOptions := &boxOption.TrojanOutboundOptions{
+		ServerOptions: boxOption.ServerOptions{Server: "example.com", ServerPort: 443},
+		Password:      "password",
+		OutboundTLSOptionsContainer: boxOption.OutboundTLSOptionsContainer{
+			TLS: &boxOption.OutboundTLSOptions{
+				Enabled:    true,
+				ServerName: "example.com",
+				Insecure:   true,
+			},
+		},
+	}
+	servers := []boxOption.Outbound{{
+		Type:    C.TypeTrojan,
+		Tag:     "trojan",
+		Options: trojanOptions,
+	}}
+	processOptions, err := NewProcessOptions(testContext, option.OutboundProcessOptions{
+		Patch: []byte(`{"tls":{"server_name":"cdn.example.com","insecure":null,"utls":{"enabled":true,"fingerprint":"chrome"}},"tcp_fast_open":true}`),
+	})
+	require.NoError(t, err)
+	processed, _, err := processOptions.Process(servers, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, processed, 1)
+	require.Equal(t, "trojan", processed[0].Tag)
+	patchedOptions := processed[0].Options.(*boxOption.TrojanOutboundOptions)
+	require.Equal(t, "password", patchedOptions.Password)
+	require.True(t, patchedOptions.TLS.Enabled)
+	require.Equal(t, "cdn.example.com", patchedOptions.TLS.ServerName)
+	require.False(t, patchedOptions.TLS.Insecure)
+	require.Equal(t, "chrome", patchedOptions.TLS.UTLS.Fingerprint)
+	require.True(t, patchedOptions.TCPFastOpen)
+	require.Equal(t, "example.com", trojanOptions.TLS.ServerName)
+
+	processOptions, err = NewProcessOptions(testContext, option.OutboundProcessOptions{
+		Patch: []byte(`{"unknown_field":true}`),
+	})
+	require.NoError(t, err)
+	processed, _, err = processOptions.Process(servers, nil, nil)
+	require.ErrorContains(t, err, "patch outbound trojan")
+	require.Equal(t, trojanOptions, processed[0].Options)
+
+	for _, patch := range []string{`[]`, `{"tag":"renamed"}`, `{"type":"direct"}`} {
+		_, err = NewProcessOptions(testContext, option.OutboundProcessOptions{Patch: []byte(patch)})
+		require.Error(t, err, patch)
+	}
+}
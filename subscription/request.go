@@ -0,0 +1,91 @@
+package subscription
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	C "github.com/sagernet/serenity/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// newRequest builds the fetch request of a remote subscription, resolving secret values on every call
+// so that rotated tokens are picked up without a restart.
+func newRequest(subscription *Subscription) (*http.Request, error) {
+	requestURL, err := url.Parse(subscription.URL)
+	if err != nil {
+		return nil, E.Cause(err, "parse url")
+	}
+	if len(subscription.Query) > 0 {
+		query := requestURL.Query()
+		for key, value := range subscription.Query {
+			value, err = resolveSecret(value)
+			if err != nil {
+				return nil, E.Cause(err, "query ", key)
+			}
+			query.Set(key, value)
+		}
+		requestURL.RawQuery = query.Encode()
+	}
+	request, err := http.NewRequest("GET", requestURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserAgent != "" {
+		request.Header.Set("User-Agent", subscription.UserAgent)
+	} else {
+		request.Header.Set("User-Agent", F.ToString("serenity/", C.Version, " (sing-box ", C.CoreVersion(), "; Clash compatible)"))
+	}
+	for key, values := range subscription.Headers {
+		request.Header.Del(key)
+		for _, value := range values {
+			value, err = resolveSecret(value)
+			if err != nil {
+				return nil, E.Cause(err, "header ", key)
+			}
+			request.Header.Add(key, value)
+		}
+	}
+	if subscription.BasicAuth != nil {
+		username, err := resolveSecret(subscription.BasicAuth.Username)
+		if err != nil {
+			return nil, E.Cause(err, "basic auth username")
+		}
+		password, err := resolveSecret(subscription.BasicAuth.Password)
+		if err != nil {
+			return nil, E.Cause(err, "basic auth password")
+		}
+		request.SetBasicAuth(username, password)
+	} else if subscription.BearerToken != "" {
+		token, err := resolveSecret(subscription.BearerToken)
+		if err != nil {
+			return nil, E.Cause(err, "bearer token")
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request, nil
+}
+
+// resolveSecret reads values written as `env:NAME` from the environment and `file:PATH` from disk.
+// Other values are returned as is.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		envValue, loaded := os.LookupEnv(name)
+		if !loaded {
+			return "", E.New("environment variable not set: ", name)
+		}
+		return envValue, nil
+	case strings.HasPrefix(value, "file:"):
+		content, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,50 @@
+package subscription
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/serenity/option"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequest(t *testing.T) {
+	t.Setenv("SERENITY_TEST_TOKEN", "env-token")
+	cookieFile := filepath.Join(t.TempDir(), "cookie")
+	require.NoError(t, os.WriteFile(cookieFile, []byte("session=secret\n"), 0o600))
+	request, err := newRequest(&Subscription{Subscription: option.Subscription{
+		URL:       "https://example.com/sub?flag=clash",
+		UserAgent: "clash",
+		Headers: badoption.HTTPHeader{
+			"Accept": []string{"text/plain"},
+			"Cookie": []string{"file:" + cookieFile},
+		},
+		Query:       map[string]string{"token": "env:SERENITY_TEST_TOKEN"},
+		BearerToken: "env:SERENITY_TEST_TOKEN",
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/sub?flag=clash&token=env-token", request.URL.String())
+	require.Equal(t, "clash", request.Header.Get("User-Agent"))
+	require.Equal(t, "text/plain", request.Header.Get("Accept"))
+	require.Equal(t, "session=secret", request.Header.Get("Cookie"))
+	require.Equal(t, "Bearer env-token", request.Header.Get("Authorization"))
+
+	request, err = newRequest(&Subscription{Subscription: option.Subscription{
+		URL:       "https://example.com/sub",
+		BasicAuth: &option.SubscriptionBasicAuth{Username: "user", Password: "env:SERENITY_TEST_TOKEN"},
+	}})
+	require.NoError(t, err)
+	username, password, ok := request.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", username)
+	require.Equal(t, "env-token", password)
+
+	_, err = newRequest(&Subscription{Subscription: option.Subscription{
+		URL:         "https://example.com/sub",
+		BearerToken: "env:SERENITY_TEST_MISSING",
+	}})
+	require.ErrorContains(t, err, "environment variable not set: SERENITY_TEST_MISSING")
+}
@@ -0,0 +1,74 @@
+package subscription
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	maxSubscriptionSize = 32 * 1024 * 1024
+	acceptEncoding      = "gzip, br"
+	maxRefreshHint      = 7 * 24 * time.Hour
+)
+
+// readBody reads the response body, decoding gzip and brotli, and fails if the
+// decoded content exceeds maxSubscriptionSize.
+func readBody(response *http.Response) ([]byte, error) {
+	var reader io.Reader = response.Body
+	switch strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, E.Cause(err, "decode gzip body")
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "br":
+		reader = brotli.NewReader(reader)
+	default:
+		return nil, E.New("unsupported content encoding: ", response.Header.Get("Content-Encoding"))
+	}
+	content, err := io.ReadAll(io.LimitReader(reader, maxSubscriptionSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxSubscriptionSize {
+		return nil, E.New("subscription content exceeds ", maxSubscriptionSize>>20, " MiB")
+	}
+	return content, nil
+}
+
+// refreshHint returns the minimum delay before the next fetch requested by the provider,
+// from `Profile-Update-Interval` (in hours) or `Cache-Control: max-age` (in seconds).
+func refreshHint(header http.Header) time.Duration {
+	var hint time.Duration
+	if updateInterval := strings.TrimSpace(header.Get("Profile-Update-Interval")); updateInterval != "" {
+		hours, err := strconv.ParseFloat(updateInterval, 64)
+		if err == nil && hours > 0 {
+			hint = time.Duration(hours * float64(time.Hour))
+		}
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if !found || !strings.EqualFold(key, "max-age") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(value, "\""), 10, 64)
+		if err == nil && time.Duration(seconds)*time.Second > hint {
+			hint = time.Duration(seconds) * time.Second
+		}
+	}
+	if hint > maxRefreshHint {
+		return maxRefreshHint
+	}
+	return hint
+}
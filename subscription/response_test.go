@@ -0,0 +1,78 @@
+package subscription
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBody(t *testing.T) {
+	t.Parallel()
+	const content = "ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example\n"
+	var gzipContent bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipContent)
+	gzipWriter.Write([]byte(content))
+	require.NoError(t, gzipWriter.Close())
+	var brotliContent bytes.Buffer
+	brotliWriter := brotli.NewWriter(&brotliContent)
+	brotliWriter.Write([]byte(content))
+	require.NoError(t, brotliWriter.Close())
+	for _, testCase := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"", []byte(content)},
+		{"gzip", gzipContent.Bytes()},
+		{"br", brotliContent.Bytes()},
+	} {
+		response := &http.Response{
+			Header: http.Header{"Content-Encoding": {testCase.encoding}},
+			Body:   io.NopCloser(bytes.NewReader(testCase.body)),
+		}
+		body, err := readBody(response)
+		require.NoError(t, err, testCase.encoding)
+		require.Equal(t, content, string(body), testCase.encoding)
+	}
+	_, err := readBody(&http.Response{
+		Header: http.Header{"Content-Encoding": {"compress"}},
+		Body:   io.NopCloser(strings.NewReader(content)),
+	})
+	require.ErrorContains(t, err, "unsupported content encoding")
+	_, err = readBody(&http.Response{
+		Header: http.Header{},
+		Body:   io.NopCloser(io.LimitReader(zeroReader{}, maxSubscriptionSize+1)),
+	})
+	require.ErrorContains(t, err, "exceeds")
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestRefreshHint(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		header   http.Header
+		expected time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Profile-Update-Interval": {"24"}}, 24 * time.Hour},
+		{http.Header{"Profile-Update-Interval": {"0.5"}}, 30 * time.Minute},
+		{http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute},
+		{http.Header{"Cache-Control": {"max-age=7200"}, "Profile-Update-Interval": {"1"}}, 2 * time.Hour},
+		{http.Header{"Profile-Update-Interval": {"invalid"}}, 0},
+		{http.Header{"Profile-Update-Interval": {"1000"}}, maxRefreshHint},
+	} {
+		require.Equal(t, testCase.expected, refreshHint(testCase.header), testCase.header)
+	}
+}
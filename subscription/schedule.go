@@ -0,0 +1,121 @@
+package subscription
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	maxConcurrentUpdates = 4
+	// local files are cheap to check, so they are polled at least this often
+	localPollInterval = time.Minute
+	minRetryInterval  = 30 * time.Second
+	// retries back off up to this multiple of the update interval
+	maxRetryFactor = 8
+)
+
+func (m *Manager) loopUpdate(subscription *Subscription) {
+	for {
+		timer := time.NewTimer(time.Until(subscription.nextUpdate))
+		select {
+		case <-timer.C:
+			m.scheduledUpdate(subscription)
+		case <-m.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// updateAll updates all due subscriptions concurrently and waits for them to finish.
+func (m *Manager) updateAll() {
+	var waitGroup sync.WaitGroup
+	now := time.Now()
+	for _, subscription := range m.subscriptions {
+		if subscription.Content != "" || subscription.IsComposite() {
+			continue
+		}
+		if subscription.nextUpdate.IsZero() {
+			subscription.nextUpdate = subscription.lastUpdated.Add(subscription.updateInterval())
+		}
+		if subscription.localPath == "" && now.Before(subscription.nextUpdate) {
+			continue
+		}
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			m.scheduledUpdate(subscription)
+		}()
+	}
+	waitGroup.Wait()
+}
+
+func (m *Manager) scheduledUpdate(subscription *Subscription) {
+	select {
+	case m.updateSemaphore <- struct{}{}:
+	case <-m.ctx.Done():
+		return
+	}
+	err := m.update(subscription)
+	<-m.updateSemaphore
+	lastAttempt := time.Now()
+	subscription.publish(func(state *State) {
+		state.UserInfo = subscription.userInfo
+		state.LastUpdated = subscription.lastUpdated
+		state.LastAttempt = lastAttempt
+		state.LastError = err
+	})
+	if err == nil {
+		subscription.failures = 0
+		nextInterval := jitter(subscription.updateInterval())
+		// the provider's refresh hint is a lower bound
+		if nextInterval < subscription.refreshHint {
+			nextInterval = subscription.refreshHint
+		}
+		subscription.nextUpdate = lastAttempt.Add(nextInterval)
+		return
+	}
+	if m.ctx.Err() != nil {
+		return
+	}
+	subscription.failures++
+	retryInterval := subscription.retryInterval()
+	subscription.nextUpdate = lastAttempt.Add(jitter(retryInterval))
+	if subscription.Rejected() {
+		m.logger.Warn(E.Cause(err, "update subscription ", subscription.Name, " (attempt ", subscription.failures, ", retry in ", retryInterval, ", keeping ", len(subscription.rawServers)+len(subscription.rawEndpoints), " previous servers)"))
+		return
+	}
+	m.logger.Error(E.Cause(err, "update subscription ", subscription.Name, " (attempt ", subscription.failures, ", retry in ", retryInterval, ")"))
+}
+
+func (s *Subscription) updateInterval() time.Duration {
+	if s.localPath != "" && s.interval > localPollInterval {
+		return localPollInterval
+	}
+	return s.interval
+}
+
+// retryInterval doubles from minRetryInterval with each consecutive failure.
+func (s *Subscription) retryInterval() time.Duration {
+	maxInterval := s.updateInterval() * maxRetryFactor
+	retryInterval := minRetryInterval
+	for i := 1; i < s.failures && retryInterval < maxInterval; i++ {
+		retryInterval *= 2
+	}
+	if retryInterval > maxInterval {
+		retryInterval = maxInterval
+	}
+	return retryInterval
+}
+
+// jitter spreads updates by up to 10% of the interval in either direction.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
@@ -0,0 +1,176 @@
+package subscription
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/serenity/option"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryInterval(t *testing.T) {
+	t.Parallel()
+	subscription := &Subscription{interval: time.Hour}
+	for _, testCase := range []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{20, 8 * time.Hour},
+	} {
+		subscription.failures = testCase.failures
+		require.Equal(t, testCase.expected, subscription.retryInterval(), "failures: ", testCase.failures)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < 100; i++ {
+		interval := jitter(time.Hour)
+		require.GreaterOrEqual(t, interval, 54*time.Minute)
+		require.LessOrEqual(t, interval, 66*time.Minute)
+	}
+}
+
+func newScheduleServer(t *testing.T, handler func(writer http.ResponseWriter, request *http.Request)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestScheduleIndependent(t *testing.T) {
+	t.Parallel()
+	var requestsA, requestsB atomic.Int32
+	server := newScheduleServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/a" {
+			requestsA.Add(1)
+		} else {
+			requestsB.Add(1)
+		}
+		io.WriteString(writer, "trojan://password@1.1.1.1:443#"+request.URL.Path[1:]+"\n")
+	})
+	manager := newTestManager(t, option.Subscription{
+		Name:           "a",
+		URL:            server.URL + "/a",
+		UpdateInterval: badoption.Duration(time.Hour),
+	}, option.Subscription{
+		Name:           "b",
+		URL:            server.URL + "/b",
+		UpdateInterval: badoption.Duration(4 * time.Hour),
+	})
+	a, b := manager.Subscriptions()[0], manager.Subscriptions()[1]
+	manager.updateAll()
+	require.Equal(t, int32(1), requestsA.Load())
+	require.Equal(t, int32(1), requestsB.Load())
+	require.InDelta(t, time.Hour, a.nextUpdate.Sub(a.State().LastAttempt), float64(6*time.Minute))
+	require.InDelta(t, 4*time.Hour, b.nextUpdate.Sub(b.State().LastAttempt), float64(24*time.Minute))
+
+	// only subscriptions that are due are updated
+	manager.updateAll()
+	require.Equal(t, int32(1), requestsA.Load())
+	require.Equal(t, int32(1), requestsB.Load())
+	a.nextUpdate = time.Now().Add(-time.Second)
+	nextUpdateB := b.nextUpdate
+	manager.updateAll()
+	require.Equal(t, int32(2), requestsA.Load())
+	require.Equal(t, int32(1), requestsB.Load())
+	require.Equal(t, nextUpdateB, b.nextUpdate)
+}
+
+func TestScheduleBackoff(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := newScheduleServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		if requests.Add(1) <= 2 {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(writer, "trojan://password@1.1.1.1:443#test\n")
+	})
+	manager := newTestManager(t, option.Subscription{
+		Name: "test",
+		URL:  server.URL,
+	})
+	subscription := manager.Subscriptions()[0]
+	retryIn := func() time.Duration {
+		return subscription.nextUpdate.Sub(subscription.State().LastAttempt)
+	}
+	manager.scheduledUpdate(subscription)
+	require.Error(t, subscription.State().LastError)
+	require.Equal(t, 1, subscription.failures)
+	require.InDelta(t, 30*time.Second, retryIn(), float64(3*time.Second))
+
+	manager.scheduledUpdate(subscription)
+	require.Error(t, subscription.State().LastError)
+	require.Equal(t, 2, subscription.failures)
+	require.InDelta(t, time.Minute, retryIn(), float64(6*time.Second))
+
+	// a successful update resets the backoff
+	manager.scheduledUpdate(subscription)
+	require.NoError(t, subscription.State().LastError)
+	require.Equal(t, 0, subscription.failures)
+	require.Len(t, subscription.State().Servers, 1)
+	require.False(t, subscription.State().LastUpdated.IsZero())
+	require.InDelta(t, time.Hour, retryIn(), float64(6*time.Minute))
+}
+
+func TestScheduleConcurrency(t *testing.T) {
+	t.Parallel()
+	var inFlight, maxInFlight, requests atomic.Int32
+	release := make(chan struct{})
+	server := newScheduleServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			loaded := maxInFlight.Load()
+			if current <= loaded || maxInFlight.CompareAndSwap(loaded, current) {
+				break
+			}
+		}
+		<-release
+		io.WriteString(writer, "trojan://password@1.1.1.1:443#"+request.URL.Path[1:]+"\n")
+	})
+	// unblock the handlers before the server is closed if the test fails early
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	var subscriptions []option.Subscription
+	for i := 0; i < maxConcurrentUpdates+2; i++ {
+		name := F.ToString(i)
+		subscriptions = append(subscriptions, option.Subscription{Name: name, URL: server.URL + "/" + name})
+	}
+	manager := newTestManager(t, subscriptions...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.updateAll()
+	}()
+	require.Eventually(t, func() bool {
+		return inFlight.Load() == maxConcurrentUpdates
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, int32(maxConcurrentUpdates), requests.Load())
+	close(release)
+	<-done
+	require.Equal(t, int32(maxConcurrentUpdates+2), requests.Load())
+	require.Equal(t, int32(maxConcurrentUpdates), maxInFlight.Load())
+	for _, subscription := range manager.Subscriptions() {
+		require.NoError(t, subscription.State().LastError)
+		require.Len(t, subscription.State().Servers, 1)
+	}
+}
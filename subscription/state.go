@@ -0,0 +1,38 @@
+package subscription
+
+import (
+	"time"
+
+	"github.com/sagernet/serenity/common/userinfo"
+	boxOption "github.com/sagernet/sing-box/option"
+)
+
+// State is the published state of a subscription.
+// It is replaced as a whole on every change and must not be modified,
+// so that it can be read while the subscription is being updated.
+type State struct {
+	Servers     []boxOption.Outbound
+	Endpoints   []boxOption.Endpoint
+	UserInfo    *userinfo.Info
+	LastUpdated time.Time
+	LastAttempt time.Time
+	LastError   error
+}
+
+// State returns the last published state of the subscription.
+func (s *Subscription) State() *State {
+	state := s.state.Load()
+	if state == nil {
+		return &State{}
+	}
+	return state
+}
+
+// publish applies the change to a copy of the current state and publishes the copy.
+func (s *Subscription) publish(change func(state *State)) {
+	s.stateAccess.Lock()
+	defer s.stateAccess.Unlock()
+	state := *s.State()
+	change(&state)
+	s.state.Store(&state)
+}
@@ -1,123 +1,323 @@
 package subscription
 
 import (
+	"bytes"
 	"context"
-	"io"
+	"crypto/sha256"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/serenity/common/cachefile"
-	C "github.com/sagernet/serenity/constant"
+	"github.com/sagernet/serenity/common/geoip"
+	"github.com/sagernet/serenity/common/userinfo"
 	"github.com/sagernet/serenity/option"
 	"github.com/sagernet/serenity/subscription/parser"
+	"github.com/sagernet/sing-box/adapter"
 	boxOption "github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
-	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/logger"
 )
 
 type Manager struct {
-	ctx            context.Context
-	cancel         context.CancelFunc
-	logger         logger.Logger
-	cacheFile      *cachefile.CacheFile
-	subscriptions  []*Subscription
-	updateInterval time.Duration
-	updateTicker   *time.Ticker
-	httpClient     http.Client
+	ctx             context.Context
+	cancel          context.CancelFunc
+	logger          logger.ContextLogger
+	cacheFile       *cachefile.CacheFile
+	subscriptions   []*Subscription
+	updateSemaphore chan struct{}
+	httpClient      http.Client
+	compositeAccess sync.Mutex
+	resolveCtx      *resolveContext
+	geoIPPath       string
+	geoIPReader     *geoip.Reader
+	countryAccess   sync.RWMutex
+	countries       map[string]string
+	// countryReady is set after Start, so that resolving server addresses does not delay the startup
+	countryReady bool
 }
 
 type Subscription struct {
 	option.Subscription
-	rawServers  []boxOption.Outbound
-	processes   []*ProcessOptions
-	Servers     []boxOption.Outbound
-	LastUpdated time.Time
-	LastEtag    string
+	rawServers   []boxOption.Outbound
+	rawEndpoints []boxOption.Endpoint
+	processes    []*ProcessOptions
+	// state is published by the update of the subscription and read by renders and composites
+	state        atomic.Pointer[State]
+	stateAccess  sync.Mutex
+	lastUpdated  time.Time
+	LastEtag     string
+	LastModified string
+	userInfo     *userinfo.Info
+	// PinnedVersion is the history version the subscription is pinned to, or 0
+	PinnedVersion uint64
+	localPath     string
+	fileModTime   time.Time
+	contentHash   []byte
+	// replacedHash is the hash of the content replaced by a rollback, see cachefile.Subscription
+	replacedHash []byte
+	rawContent   []byte
+	// parserVersion is the parser.Version that rawServers were parsed with
+	parserVersion uint32
+	refreshHint   time.Duration
+	detour        adapter.Outbound
+	httpClient    *http.Client
+	interval      time.Duration
+	historySize   int
+	members       []*Subscription
+	// countryPending is set if the subscription was processed before country lookups were ready
+	countryPending bool
+	failures       int
+	nextUpdate     time.Time
 }
 
-func NewSubscriptionManager(ctx context.Context, logger logger.Logger, cacheFile *cachefile.CacheFile, rawSubscriptions []option.Subscription) (*Manager, error) {
+func NewSubscriptionManager(ctx context.Context, logger logger.ContextLogger, cacheFile *cachefile.CacheFile, geoIPPath string, outbounds [][]boxOption.Outbound, rawSubscriptions []option.Subscription) (*Manager, error) {
 	var (
 		subscriptions []*Subscription
-		interval      time.Duration
+		needCountry   bool
 	)
 	for index, subscription := range rawSubscriptions {
 		if subscription.Name == "" {
 			return nil, E.New("initialize subscription[", index, "]: missing name")
 		}
-		var processes []*ProcessOptions
-		if interval == 0 || time.Duration(subscription.UpdateInterval) < interval {
-			interval = time.Duration(subscription.UpdateInterval)
+		var localPath string
+		switch {
+		case len(subscription.Include) > 0 && (subscription.URL != "" || subscription.Content != ""):
+			return nil, E.New("initialize subscription[", subscription.Name, "]: `include` and `url` or `content` are mutually exclusive")
+		case len(subscription.Include) > 0:
+		case subscription.URL != "" && subscription.Content != "":
+			return nil, E.New("initialize subscription[", subscription.Name, "]: `url` and `content` are mutually exclusive")
+		case subscription.URL == "" && subscription.Content == "":
+			return nil, E.New("initialize subscription[", subscription.Name, "]: missing `url` or `content`")
+		case strings.HasPrefix(subscription.URL, "file://"):
+			localPath = strings.TrimPrefix(subscription.URL, "file://")
+			if localPath == "" {
+				return nil, E.New("initialize subscription[", subscription.Name, "]: missing file path")
+			}
 		}
+		if !parser.IsValidFormat(subscription.Format) {
+			return nil, E.New("initialize subscription[", subscription.Name, "]: unknown format: ", subscription.Format)
+		}
+		var processes []*ProcessOptions
 		for processIndex, process := range subscription.Process {
-			processOptions, err := NewProcessOptions(process)
+			processOptions, err := NewProcessOptions(ctx, process)
 			if err != nil {
 				return nil, E.Cause(err, "initialize subscription[", subscription.Name, "]: parse process[", processIndex, "]")
 			}
 			processes = append(processes, processOptions)
+			needCountry = needCountry || processOptions.NeedCountry()
+		}
+		if subscription.MinServers < 0 {
+			return nil, E.New("initialize subscription[", subscription.Name, "]: invalid `min_servers`: ", subscription.MinServers)
+		}
+		if subscription.MaxDropPercent < 0 || subscription.MaxDropPercent > 100 {
+			return nil, E.New("initialize subscription[", subscription.Name, "]: invalid `max_drop_percent`: ", subscription.MaxDropPercent)
+		}
+		if subscription.HistorySize < 0 {
+			return nil, E.New("initialize subscription[", subscription.Name, "]: invalid `history_size`: ", subscription.HistorySize)
+		}
+		if subscription.BasicAuth != nil && subscription.BearerToken != "" {
+			return nil, E.New("initialize subscription[", subscription.Name, "]: `basic_auth` and `bearer_token` are mutually exclusive")
+		}
+		if subscription.URL != "" && localPath == "" {
+			_, err := newRequest(&Subscription{Subscription: subscription})
+			if err != nil {
+				return nil, E.Cause(err, "initialize subscription[", subscription.Name, "]")
+			}
+		}
+		var detour adapter.Outbound
+		if subscription.Detour != "" {
+			if subscription.URL == "" || localPath != "" {
+				return nil, E.New("initialize subscription[", subscription.Name, "]: `detour` is only supported for remote subscriptions")
+			}
+			var err error
+			detour, err = newDetourOutbound(ctx, logger, outbounds, subscription.Detour)
+			if err != nil {
+				return nil, E.Cause(err, "initialize subscription[", subscription.Name, "]: initialize detour")
+			}
+		}
+		interval := time.Duration(subscription.UpdateInterval)
+		if interval == 0 {
+			interval = option.DefaultSubscriptionUpdateInterval
+		}
+		historySize := subscription.HistorySize
+		if historySize == 0 {
+			historySize = option.DefaultSubscriptionHistorySize
 		}
 		subscriptions = append(subscriptions, &Subscription{
 			Subscription: subscription,
 			processes:    processes,
+			localPath:    localPath,
+			detour:       detour,
+			interval:     interval,
+			historySize:  historySize,
 		})
 	}
-	if interval == 0 {
-		interval = option.DefaultSubscriptionUpdateInterval
+	err := resolveMembers(subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	if !needCountry {
+		geoIPPath = ""
+	} else if geoIPPath == "" {
+		geoIPPath = "geoip.db"
 	}
 	ctx, cancel := context.WithCancel(ctx)
 	return &Manager{
-		ctx:            ctx,
-		cancel:         cancel,
-		logger:         logger,
-		cacheFile:      cacheFile,
-		subscriptions:  subscriptions,
-		updateInterval: interval,
+		ctx:             ctx,
+		cancel:          cancel,
+		logger:          logger,
+		cacheFile:       cacheFile,
+		subscriptions:   subscriptions,
+		updateSemaphore: make(chan struct{}, maxConcurrentUpdates),
+		resolveCtx:      newResolveContext(ctx),
+		geoIPPath:       geoIPPath,
+		countries:       make(map[string]string),
 	}, nil
 }
 
 func (m *Manager) Start() error {
+	if m.geoIPPath != "" {
+		geoIPReader, err := geoip.Open(m.geoIPPath)
+		if err != nil {
+			return E.Cause(err, "open geoip database")
+		}
+		m.geoIPReader = geoIPReader
+	}
 	for _, subscription := range m.subscriptions {
+		if subscription.IsComposite() {
+			continue
+		}
+		if subscription.detour != nil {
+			err := startDetourOutbound(subscription.detour)
+			if err != nil {
+				return E.Cause(err, "initialize subscription[", subscription.Name, "]: start detour")
+			}
+			subscription.httpClient = newDetourHTTPClient(subscription.detour)
+		}
+		if subscription.Content != "" {
+			err := m.updateLocal(subscription)
+			if err != nil {
+				return E.Cause(err, "initialize subscription[", subscription.Name, "]")
+			}
+			continue
+		}
+		// local files are read by the first scheduled update, so a missing file does not prevent startup
 		savedSubscription := m.cacheFile.LoadSubscription(subscription.Name)
 		if savedSubscription != nil {
 			subscription.rawServers = savedSubscription.Content
-			subscription.LastUpdated = savedSubscription.LastUpdated
+			subscription.rawEndpoints = savedSubscription.Endpoints
+			subscription.lastUpdated = savedSubscription.LastUpdated
 			subscription.LastEtag = savedSubscription.LastEtag
-			m.processSubscription(subscription, false)
+			subscription.LastModified = savedSubscription.LastModified
+			subscription.contentHash = savedSubscription.ContentHash
+			subscription.replacedHash = savedSubscription.ReplacedHash
+			subscription.userInfo = savedSubscription.UserInfo
+			subscription.rawContent = savedSubscription.RawContent
+			subscription.parserVersion = savedSubscription.ParserVersion
+			if subscription.parserVersion != parser.Version && len(subscription.rawContent) > 0 {
+				m.reparseCached(subscription)
+			}
+		}
+		if pinnedVersion := m.cacheFile.LoadSubscriptionPin(subscription.Name); pinnedVersion != 0 {
+			pinnedSubscription := m.cacheFile.LoadSubscriptionHistory(subscription.Name, pinnedVersion)
+			if pinnedSubscription == nil {
+				m.logger.Warn("subscription ", subscription.Name, " is pinned to missing version ", pinnedVersion)
+			} else {
+				subscription.rawServers = pinnedSubscription.Content
+				subscription.rawEndpoints = pinnedSubscription.Endpoints
+				subscription.PinnedVersion = pinnedVersion
+				m.logger.Info("subscription ", subscription.Name, " is pinned to version ", pinnedVersion)
+			}
+		}
+		m.processSubscription(subscription, false)
+	}
+	for _, subscription := range m.subscriptions {
+		if subscription.IsComposite() {
+			m.refreshComposite(subscription, false)
 		}
 	}
 	return nil
 }
 
 func (m *Manager) processSubscription(s *Subscription, onUpdate bool) {
-	servers := s.rawServers
-	for _, process := range s.processes {
-		servers = process.Process(servers)
+	servers, endpoints := s.rawServers, s.rawEndpoints
+	var lookupCountry CountryLookup
+	if m.needCountry(s) {
+		s.countryPending = !m.countryReady
+		if !s.countryPending {
+			// composites are resolved in refreshComposite, before taking compositeAccess
+			if !s.IsComposite() {
+				m.resolveCountries(servers)
+			}
+			lookupCountry = m.lookupCountry
+		}
+	}
+	for processIndex, process := range s.processes {
+		if s.countryPending && process.NeedCountry() {
+			// applied by processCountryPending once countries are resolved
+			continue
+		}
+		var err error
+		servers, endpoints, err = process.Process(servers, endpoints, lookupCountry)
+		if err != nil {
+			for _, patchErr := range E.Expand(err) {
+				m.logger.Warn("process subscription ", s.Name, ": process[", processIndex, "]: ", patchErr)
+			}
+		}
 	}
 	if s.DeDuplication {
 		originLen := len(servers)
-		servers = Deduplication(m.ctx, servers)
+		servers = deduplication(m.resolveCtx, servers)
 		if onUpdate && originLen != len(servers) {
 			m.logger.Info("excluded ", originLen-len(servers), " duplicated servers in ", s.Name)
 		}
 	}
-	s.Servers = servers
+	s.publish(func(state *State) {
+		state.Servers = servers
+		state.Endpoints = endpoints
+		state.UserInfo = s.userInfo
+		state.LastUpdated = s.lastUpdated
+	})
+	if onUpdate && !s.IsComposite() {
+		m.refreshComposites(s)
+	}
 }
 
 func (m *Manager) PostStart(headless bool) error {
+	m.countryReady = true
 	m.updateAll()
+	m.processCountryPending()
 	if !headless {
-		m.updateTicker = time.NewTicker(m.updateInterval)
-		go m.loopUpdate()
+		for _, subscription := range m.subscriptions {
+			if subscription.Content != "" || subscription.IsComposite() {
+				continue
+			}
+			go m.loopUpdate(subscription)
+		}
 	}
 	return nil
 }
 
 func (m *Manager) Close() error {
-	if m.updateTicker != nil {
-		m.updateTicker.Stop()
-	}
 	m.cancel()
 	m.httpClient.CloseIdleConnections()
+	m.resolveCtx.Close()
+	if m.geoIPReader != nil {
+		m.geoIPReader.Close()
+	}
+	for _, subscription := range m.subscriptions {
+		if subscription.httpClient != nil {
+			subscription.httpClient.CloseIdleConnections()
+		}
+		if subscription.detour != nil {
+			if closer, isCloser := subscription.detour.(adapter.Lifecycle); isCloser {
+				closer.Close()
+			}
+		}
+	}
 	return nil
 }
 
@@ -125,89 +325,173 @@ func (m *Manager) Subscriptions() []*Subscription {
 	return m.subscriptions
 }
 
-func (m *Manager) loopUpdate() {
-	for {
-		select {
-		case <-m.updateTicker.C:
-			m.updateAll()
-		case <-m.ctx.Done():
-			return
-		}
+func (m *Manager) update(subscription *Subscription) error {
+	if subscription.localPath != "" {
+		return m.updateLocal(subscription)
 	}
-}
-
-func (m *Manager) updateAll() {
-	for _, subscription := range m.subscriptions {
-		if time.Since(subscription.LastUpdated) < m.updateInterval {
-			continue
-		}
-		err := m.update(subscription)
-		if err != nil {
-			m.logger.Error(E.Cause(err, "update subscription ", subscription.Name))
-		}
+	if subscription.PinnedVersion != 0 {
+		m.logger.Debug("skip update of subscription ", subscription.Name, ": pinned to version ", subscription.PinnedVersion)
+		return nil
 	}
-}
-
-func (m *Manager) update(subscription *Subscription) error {
-	request, err := http.NewRequest("GET", subscription.URL, nil)
+	request, err := newRequest(subscription)
 	if err != nil {
 		return err
 	}
-	if subscription.UserAgent != "" {
-		request.Header.Set("User-Agent", subscription.UserAgent)
-	} else {
-		request.Header.Set("User-Agent", F.ToString("serenity/", C.Version, " (sing-box ", C.CoreVersion(), "; Clash compatible)"))
-	}
 	if subscription.LastEtag != "" {
 		request.Header.Set("If-None-Match", subscription.LastEtag)
 	}
-	response, err := m.httpClient.Do(request.WithContext(m.ctx))
+	if subscription.LastModified != "" {
+		request.Header.Set("If-Modified-Since", subscription.LastModified)
+	}
+	request.Header.Set("Accept-Encoding", acceptEncoding)
+	httpClient := &m.httpClient
+	if subscription.httpClient != nil {
+		httpClient = subscription.httpClient
+	}
+	response, err := httpClient.Do(request.WithContext(m.ctx))
 	if err != nil {
 		return err
 	}
-	switch response.StatusCode {
-	case http.StatusOK:
-	case http.StatusNotModified:
-		subscription.LastUpdated = time.Now()
-		err = m.cacheFile.StoreSubscription(subscription.Name, &cachefile.Subscription{
-			Content:     subscription.rawServers,
-			LastUpdated: subscription.LastUpdated,
-			LastEtag:    subscription.LastEtag,
-		})
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotModified {
+		return E.New("unexpected status: ", response.Status)
+	}
+	// providers keep sending usage data when the servers are not modified
+	m.updateUserInfo(subscription, response.Header)
+	if response.StatusCode == http.StatusNotModified {
+		subscription.refreshHint = refreshHint(response.Header)
+		subscription.lastUpdated = time.Now()
+		err = m.storeSubscription(subscription)
 		if err != nil {
 			return err
 		}
 		m.logger.Info("updated subscription ", subscription.Name, ": not modified")
 		return nil
-	default:
-		return E.New("unexpected status: ", response.Status)
 	}
-	content, err := io.ReadAll(response.Body)
+	content, err := readBody(response)
 	if err != nil {
-		response.Body.Close()
 		return err
 	}
-	rawServers, err := parser.ParseSubscription(m.ctx, string(content))
+	updated, err := m.parseContent(subscription, content)
 	if err != nil {
-		response.Body.Close()
 		return err
 	}
-	response.Body.Close()
-	subscription.rawServers = rawServers
-	m.processSubscription(subscription, true)
+	subscription.refreshHint = refreshHint(response.Header)
 	eTagHeader := response.Header.Get("Etag")
 	if eTagHeader != "" {
 		subscription.LastEtag = eTagHeader
 	}
-	subscription.LastUpdated = time.Now()
-	err = m.cacheFile.StoreSubscription(subscription.Name, &cachefile.Subscription{
-		Content:     subscription.rawServers,
-		LastUpdated: subscription.LastUpdated,
-		LastEtag:    subscription.LastEtag,
-	})
+	subscription.LastModified = response.Header.Get("Last-Modified")
+	subscription.lastUpdated = time.Now()
+	err = m.storeSubscription(subscription)
 	if err != nil {
 		return err
 	}
-	m.logger.Info("updated subscription ", subscription.Name, ": ", len(subscription.rawServers), " servers")
+	if updated {
+		m.logUpdated(subscription)
+		_, err = m.cacheFile.StoreSubscriptionHistory(subscription.Name, subscription.cacheRecord(), subscription.historySize)
+		if err != nil {
+			m.logger.Warn("store history of subscription ", subscription.Name, ": ", err)
+		}
+	} else {
+		m.logger.Info("updated subscription ", subscription.Name, ": content unchanged")
+	}
 	return nil
 }
+
+func (m *Manager) updateUserInfo(subscription *Subscription, header http.Header) {
+	userInfoHeader := header.Get(userinfo.HeaderName)
+	if userInfoHeader == "" {
+		return
+	}
+	userInfo, err := userinfo.Parse(userInfoHeader)
+	if err != nil {
+		m.logger.Warn("parse ", userinfo.HeaderName, " of subscription ", subscription.Name, ": ", err)
+		return
+	}
+	subscription.userInfo = userInfo
+	m.logger.Info("subscription ", subscription.Name, " usage: ", userInfo)
+}
+
+// parseContent parses fetched content into the raw servers of the subscription and processes them.
+// It reports false without parsing if the content is the same as last time.
+func (m *Manager) parseContent(subscription *Subscription, content []byte) (bool, error) {
+	contentHash := sha256.New()
+	contentHash.Write([]byte(subscription.Format))
+	contentHash.Write([]byte{0})
+	contentHash.Write(content)
+	contentSum := contentHash.Sum(nil)
+	if bytes.Equal(contentSum, subscription.contentHash) && subscription.parserVersion == parser.Version && (len(subscription.rawServers) > 0 || len(subscription.rawEndpoints) > 0) {
+		return false, nil
+	}
+	// the content that was rolled back is still served
+	if bytes.Equal(contentSum, subscription.replacedHash) {
+		return false, nil
+	}
+	rawServers, rawEndpoints, err := parser.ParseSubscriptionFormat(m.ctx, subscription.Format, string(content))
+	if len(rawServers) == 0 && len(rawEndpoints) == 0 {
+		return false, err
+	}
+	skipped, warnings := parser.SplitWarnings(err)
+	if len(skipped) > 0 && subscription.Strict {
+		return false, E.Errors(skipped...)
+	}
+	for _, warning := range append(skipped, warnings...) {
+		m.logger.Warn("parse subscription ", subscription.Name, ": ", warning)
+	}
+	err = subscription.checkUpdate(len(rawServers) + len(rawEndpoints))
+	if err != nil {
+		return false, err
+	}
+	subscription.rawServers = rawServers
+	subscription.rawEndpoints = rawEndpoints
+	if !bytes.Equal(contentSum, subscription.contentHash) {
+		subscription.replacedHash = nil
+	}
+	subscription.contentHash = contentSum
+	subscription.rawContent = content
+	subscription.parserVersion = parser.Version
+	m.processSubscription(subscription, true)
+	return true, nil
+}
+
+func (m *Manager) storeSubscription(subscription *Subscription) error {
+	return m.cacheFile.StoreSubscription(subscription.Name, subscription.cacheRecord())
+}
+
+func (s *Subscription) cacheRecord() *cachefile.Subscription {
+	return &cachefile.Subscription{
+		Content:       s.rawServers,
+		Endpoints:     s.rawEndpoints,
+		LastUpdated:   s.lastUpdated,
+		LastEtag:      s.LastEtag,
+		UserInfo:      s.userInfo,
+		LastModified:  s.LastModified,
+		ContentHash:   s.contentHash,
+		RawContent:    s.rawContent,
+		ParserVersion: s.parserVersion,
+		ReplacedHash:  s.replacedHash,
+	}
+}
+
+// reparseCached parses the cached body of the subscription again, after an upgrade changed the parser version.
+func (m *Manager) reparseCached(subscription *Subscription) {
+	_, err := m.parseContent(subscription, subscription.rawContent)
+	if err != nil {
+		m.logger.Warn("re-parse cached subscription ", subscription.Name, ": ", err)
+		return
+	}
+	err = m.storeSubscription(subscription)
+	if err != nil {
+		m.logger.Warn("store subscription ", subscription.Name, ": ", err)
+	}
+	m.logger.Info("re-parsed cached subscription ", subscription.Name, " with parser version ", parser.Version)
+}
+
+func (m *Manager) logUpdated(subscription *Subscription) {
+	if len(subscription.rawEndpoints) > 0 {
+		m.logger.Info("updated subscription ", subscription.Name, ": ", len(subscription.rawServers), " servers, ", len(subscription.rawEndpoints), " endpoints")
+	} else {
+		m.logger.Info("updated subscription ", subscription.Name, ": ", len(subscription.rawServers), " servers")
+	}
+}
@@ -0,0 +1,199 @@
+package subscription
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/serenity/common/cachefile"
+	"github.com/sagernet/serenity/common/userinfo"
+	"github.com/sagernet/serenity/option"
+	"github.com/sagernet/serenity/subscription/parser"
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/log"
+	boxOption "github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testContext = box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+
+// newTestCacheFile opens a cache file in a temporary directory, closed when the test finishes.
+func newTestCacheFile(t *testing.T) *cachefile.CacheFile {
+	cacheFile := cachefile.New(testContext, filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, cacheFile.Start())
+	t.Cleanup(func() {
+		cacheFile.Close()
+	})
+	return cacheFile
+}
+
+// createTestManager creates a manager without starting it, closed when the test finishes.
+func createTestManager(t *testing.T, cacheFile *cachefile.CacheFile, geoIPPath string, outbounds [][]boxOption.Outbound, subscriptions ...option.Subscription) (*Manager, error) {
+	manager, err := NewSubscriptionManager(testContext, log.NewNOPFactory().NewLogger("subscription"), cacheFile, geoIPPath, outbounds, subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		manager.Close()
+	})
+	return manager, nil
+}
+
+// newTestManager creates and starts a manager with a new cache file.
+func newTestManager(t *testing.T, subscriptions ...option.Subscription) *Manager {
+	manager, err := createTestManager(t, newTestCacheFile(t), "", nil, subscriptions...)
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	return manager
+}
+
+func TestReparseCached(t *testing.T) {
+	t.Parallel()
+	cacheFile := newTestCacheFile(t)
+	require.NoError(t, cacheFile.StoreSubscription("test", &cachefile.Subscription{
+		Content: []boxOption.Outbound{{
+			Type:    C.TypeDirect,
+			Tag:     "outdated",
+			Options: &boxOption.DirectOutboundOptions{},
+		}},
+		LastUpdated:   time.Now(),
+		RawContent:    []byte("ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example\n"),
+		ParserVersion: parser.Version - 1,
+	}))
+	manager, err := createTestManager(t, cacheFile, "", nil, option.Subscription{
+		Name: "test",
+		URL:  "https://example.com/subscription",
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	subscription := manager.Subscriptions()[0]
+	require.Len(t, subscription.State().Servers, 1)
+	require.Equal(t, "example", subscription.State().Servers[0].Tag)
+	saved := cacheFile.LoadSubscription("test")
+	require.Equal(t, uint32(parser.Version), saved.ParserVersion)
+	require.Equal(t, "example", saved.Content[0].Tag)
+}
+
+func TestStrictWarnings(t *testing.T) {
+	t.Parallel()
+	cacheFile := newTestCacheFile(t)
+	const warningProxy = `
+  - name: "Trojan"
+    type: trojan
+    server: trojan.example.com
+    port: 443
+    password: password
+    fingerprint: 0a1b2c3d
+`
+	const brokenProxy = `
+  - name: "Broken"
+    type: unknown
+    server: broken.example.com
+    port: 443
+`
+	for _, testCase := range []struct {
+		content string
+		err     string
+	}{
+		{"proxies:" + warningProxy, ""},
+		{"proxies:" + warningProxy + brokenProxy, "skip proxy[1] Broken"},
+	} {
+		manager, err := createTestManager(t, cacheFile, "", nil, option.Subscription{
+			Name:    "test",
+			Content: testCase.content,
+			Format:  parser.FormatClash,
+			Strict:  true,
+		})
+		require.NoError(t, err)
+		err = manager.Start()
+		if testCase.err != "" {
+			require.ErrorContains(t, err, testCase.err)
+			continue
+		}
+		require.NoError(t, err)
+		require.Len(t, manager.Subscriptions()[0].State().Servers, 1)
+	}
+}
+
+func TestUserInfoNotModified(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if requests.Add(1) == 1 {
+			writer.Header().Set("ETag", "\"v1\"")
+			writer.Header().Set(userinfo.HeaderName, "upload=1; download=2; total=10")
+			io.WriteString(writer, "ss://YWVzLTEyOC1nY206cGFzcw@example.com:8388#example\n")
+			return
+		}
+		require.Equal(t, "\"v1\"", request.Header.Get("If-None-Match"))
+		writer.Header().Set(userinfo.HeaderName, "upload=3; download=4; total=10")
+		writer.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+	manager := newTestManager(t, option.Subscription{
+		Name: "test",
+		URL:  server.URL,
+	})
+	subscription := manager.Subscriptions()[0]
+	manager.scheduledUpdate(subscription)
+	require.NoError(t, subscription.State().LastError)
+	require.Equal(t, uint64(2), subscription.State().UserInfo.Download)
+	manager.scheduledUpdate(subscription)
+	require.NoError(t, subscription.State().LastError)
+	require.Equal(t, int32(2), requests.Load())
+	require.Equal(t, &userinfo.Info{Upload: 3, Download: 4, Total: 10}, subscription.State().UserInfo)
+	require.Equal(t, subscription.State().UserInfo, manager.cacheFile.LoadSubscription("test").UserInfo)
+}
+
+func TestRollbackKept(t *testing.T) {
+	t.Parallel()
+	var content atomic.Value
+	content.Store("a")
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		io.WriteString(writer, "trojan://password@1.1.1.1:443#"+content.Load().(string)+"\n")
+	}))
+	defer server.Close()
+	cacheFile := newTestCacheFile(t)
+	newManager := func() (*Manager, *Subscription) {
+		manager, err := createTestManager(t, cacheFile, "", nil, option.Subscription{
+			Name: "test",
+			URL:  server.URL,
+		})
+		require.NoError(t, err)
+		require.NoError(t, manager.Start())
+		return manager, manager.Subscriptions()[0]
+	}
+	serverTag := func(subscription *Subscription) string {
+		return subscription.State().Servers[0].Tag
+	}
+	manager, subscription := newManager()
+	require.NoError(t, manager.update(subscription))
+	content.Store("b")
+	require.NoError(t, manager.update(subscription))
+	require.Equal(t, "b", serverTag(subscription))
+	require.NoError(t, manager.Close())
+
+	entries, err := cacheFile.ListSubscriptionHistory("test")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, cacheFile.RollbackSubscription("test", entries[0].Version))
+	require.Equal(t, entries[0].ContentHash, cacheFile.LoadSubscription("test").ContentHash)
+
+	// the rolled back content is kept while the provider serves the replaced content
+	manager, subscription = newManager()
+	require.Equal(t, "a", serverTag(subscription))
+	require.NoError(t, manager.update(subscription))
+	require.Equal(t, "a", serverTag(subscription))
+	content.Store("c")
+	require.NoError(t, manager.update(subscription))
+	require.Equal(t, "c", serverTag(subscription))
+	require.Nil(t, cacheFile.LoadSubscription("test").ReplacedHash)
+}
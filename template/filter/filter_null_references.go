@@ -15,6 +15,10 @@ func filterNullGroupReference(metadata M.Metadata, options *option.Options) erro
 	outboundTags := common.Map(options.Outbounds, func(it option.Outbound) string {
 		return it.Tag
 	})
+	// endpoints can be used as outbounds too
+	outboundTags = append(outboundTags, common.Map(options.Endpoints, func(it option.Endpoint) string {
+		return it.Tag
+	})...)
 	for _, outbound := range options.Outbounds {
 		switch outboundOptions := outbound.Options.(type) {
 		case *option.SelectorOutboundOptions:
@@ -2,6 +2,7 @@ package template
 
 import (
 	"bytes"
+	"net/netip"
 	"regexp"
 	"sort"
 	"text/template"
@@ -15,9 +16,10 @@ import (
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
 )
 
-func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Options, outbounds [][]boxOption.Outbound, subscriptions []*subscription.Subscription) error {
+func (t *Template) renderOutbounds(logger logger.Logger, metadata M.Metadata, options *boxOption.Options, outbounds [][]boxOption.Outbound, subscriptions []*subscription.Subscription) error {
 	disableRuleAction := t.DisableRuleAction || (metadata.Version != nil && metadata.Version.LessThan(semver.ParseVersion("1.11.0-alpha.7")))
 	defaultTag := t.DefaultTag
 	if defaultTag == "" {
@@ -65,6 +67,35 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 	outboundToString := func(it boxOption.Outbound) string {
 		return it.Tag
 	}
+	disableEndpoints := metadata.Version != nil && metadata.Version.LessThan(semver.ParseVersion("1.11.0-alpha.19"))
+	// states are read once, so that all groups of the profile match its servers if a subscription updates meanwhile
+	subscriptionStates := make(map[*subscription.Subscription]*subscription.State, len(subscriptions))
+	for _, it := range subscriptions {
+		state := it.State()
+		if disableEndpoints && len(state.Endpoints) > 0 {
+			// WireGuard endpoints are rendered as outbounds for clients before endpoints were introduced
+			legacyState := *state
+			legacyState.Servers = append([]boxOption.Outbound(nil), state.Servers...)
+			for _, endpoint := range state.Endpoints {
+				if outbound, loaded := legacyWireGuardOutbound(endpoint); loaded {
+					legacyState.Servers = append(legacyState.Servers, outbound)
+				}
+			}
+			legacyState.Endpoints = nil
+			state = &legacyState
+		}
+		subscriptionStates[it] = state
+	}
+	subscriptionOutboundTags := func(it *subscription.Subscription) []string {
+		state := subscriptionStates[it]
+		outboundTags := common.Map(state.Servers, outboundToString)
+		if !disableEndpoints {
+			outboundTags = append(outboundTags, common.Map(state.Endpoints, func(it boxOption.Endpoint) string {
+				return it.Tag
+			})...)
+		}
+		return outboundTags
+	}
 	var globalOutboundTags []string
 	if len(outbounds) > 0 {
 		for _, outbound := range outbounds {
@@ -78,16 +109,16 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 	var (
 		allGroups         []boxOption.Outbound
 		allGroupOutbounds []boxOption.Outbound
+		allEndpoints      []boxOption.Endpoint
 		groupTags         []string
+		subscriptionTags  = make(map[string]*subscription.Subscription)
 	)
 
 	for _, it := range subscriptions {
-		if len(it.Servers) == 0 {
+		joinOutbounds := subscriptionOutboundTags(it)
+		if len(joinOutbounds) == 0 {
 			continue
 		}
-		joinOutbounds := common.Map(it.Servers, func(it boxOption.Outbound) string {
-			return it.Tag
-		})
 		if it.GenerateSelector {
 			selectorOptions := common.PtrValueOrDefault(it.CustomSelector)
 			selectorOutbound := boxOption.Outbound{
@@ -121,7 +152,25 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 		if !it.GenerateSelector && !it.GenerateURLTest {
 			globalOutboundTags = append(globalOutboundTags, joinOutbounds...)
 		}
-		allGroupOutbounds = append(allGroupOutbounds, it.Servers...)
+	}
+	// composites are added first, so that servers shared with their members keep the composite's processed copy
+	serverSubscriptions := append(common.Filter(subscriptions, (*subscription.Subscription).IsComposite), common.Filter(subscriptions, func(it *subscription.Subscription) bool {
+		return !it.IsComposite()
+	})...)
+	for _, it := range serverSubscriptions {
+		state := subscriptionStates[it]
+		for _, server := range state.Servers {
+			if addSubscriptionTag(logger, subscriptionTags, server.Tag, it) {
+				allGroupOutbounds = append(allGroupOutbounds, server)
+			}
+		}
+		if !disableEndpoints {
+			for _, endpoint := range state.Endpoints {
+				if addSubscriptionTag(logger, subscriptionTags, endpoint.Tag, it) {
+					allEndpoints = append(allEndpoints, endpoint)
+				}
+			}
+		}
 	}
 
 	var (
@@ -144,7 +193,7 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 		}
 		var outboundTags []string
 		for _, it := range subscriptions {
-			subscriptionTags := common.Filter(common.Map(it.Servers, outboundToString), func(outboundTag string) bool {
+			subscriptionTags := common.Filter(subscriptionOutboundTags(it), func(outboundTag string) bool {
 				if len(extraGroup.filter) > 0 {
 					if !common.Any(extraGroup.filter, func(it *regexp.Regexp) bool {
 						return it.MatchString(outboundTag)
@@ -208,9 +257,7 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 		}
 		sort.Strings(extraTags)
 		if len(extraTags) == 0 || extraGroup.filter != nil || extraGroup.exclude != nil {
-			extraTags = append(extraTags, common.Filter(common.FlatMap(subscriptions, func(it *subscription.Subscription) []string {
-				return common.Map(it.Servers, outboundToString)
-			}), func(outboundTag string) bool {
+			extraTags = append(extraTags, common.Filter(common.FlatMap(subscriptions, subscriptionOutboundTags), func(outboundTag string) bool {
 				if len(extraGroup.filter) > 0 {
 					if !common.Any(extraGroup.filter, func(it *regexp.Regexp) bool {
 						return it.MatchString(outboundTag)
@@ -273,9 +320,80 @@ func (t *Template) renderOutbounds(metadata M.Metadata, options *boxOption.Optio
 	options.Outbounds = groupJoin(options.Outbounds, defaultTag, false, groupTags...)
 	options.Outbounds = groupJoin(options.Outbounds, defaultTag, false, globalOutboundTags...)
 	options.Outbounds = append(options.Outbounds, allGroupOutbounds...)
+	options.Endpoints = append(options.Endpoints, allEndpoints...)
 	return nil
 }
 
+// addSubscriptionTag records the tag of a subscription server and reports whether the server should be added.
+// Composite subscriptions copy the servers of their members and are recorded first, so a tag shared by a composite
+// and one of its members is only added with the composite's copy. Other duplicates are added anyway and reported,
+// since sing-box will refuse them.
+func addSubscriptionTag(logger logger.Logger, tags map[string]*subscription.Subscription, tag string, owner *subscription.Subscription) bool {
+	existing, loaded := tags[tag]
+	if !loaded {
+		tags[tag] = owner
+		return true
+	}
+	if common.Contains(existing.Members(), owner) || common.Contains(owner.Members(), existing) {
+		return false
+	}
+	if existing == owner {
+		logger.Warn("duplicate outbound tag ", tag, " in subscription ", owner.Name)
+	} else {
+		logger.Warn("duplicate outbound tag ", tag, " in subscriptions ", existing.Name, " and ", owner.Name)
+	}
+	return true
+}
+
+// legacyWireGuardOutbound converts a WireGuard endpoint to the outbound used by clients without endpoint support.
+// Other endpoints are dropped.
+func legacyWireGuardOutbound(endpoint boxOption.Endpoint) (boxOption.Outbound, bool) {
+	endpointOptions, isWireGuard := endpoint.Options.(*boxOption.WireGuardEndpointOptions)
+	if endpoint.Type != C.TypeWireGuard || !isWireGuard {
+		return boxOption.Outbound{}, false
+	}
+	outboundOptions := &boxOption.LegacyWireGuardOutboundOptions{
+		DialerOptions:   endpointOptions.DialerOptions,
+		SystemInterface: endpointOptions.System,
+		InterfaceName:   endpointOptions.Name,
+		LocalAddress:    endpointOptions.Address,
+		PrivateKey:      endpointOptions.PrivateKey,
+		Workers:         endpointOptions.Workers,
+		MTU:             endpointOptions.MTU,
+	}
+	// a single peer routing all traffic is written inline, the legacy outbound only takes allowed IPs through the peer list
+	if len(endpointOptions.Peers) == 1 && common.All(endpointOptions.Peers[0].AllowedIPs, func(it netip.Prefix) bool {
+		return it.Bits() == 0
+	}) {
+		peer := endpointOptions.Peers[0]
+		outboundOptions.ServerOptions = boxOption.ServerOptions{
+			Server:     peer.Address,
+			ServerPort: peer.Port,
+		}
+		outboundOptions.PeerPublicKey = peer.PublicKey
+		outboundOptions.PreSharedKey = peer.PreSharedKey
+		outboundOptions.Reserved = peer.Reserved
+	} else {
+		outboundOptions.Peers = common.Map(endpointOptions.Peers, func(it boxOption.WireGuardPeer) boxOption.LegacyWireGuardPeer {
+			return boxOption.LegacyWireGuardPeer{
+				ServerOptions: boxOption.ServerOptions{
+					Server:     it.Address,
+					ServerPort: it.Port,
+				},
+				PublicKey:    it.PublicKey,
+				PreSharedKey: it.PreSharedKey,
+				AllowedIPs:   it.AllowedIPs,
+				Reserved:     it.Reserved,
+			}
+		})
+	}
+	return boxOption.Outbound{
+		Type:    C.TypeWireGuard,
+		Tag:     endpoint.Tag,
+		Options: outboundOptions,
+	}, true
+}
+
 func groupJoin(outbounds []boxOption.Outbound, groupTag string, appendFront bool, groupOutbounds ...string) []boxOption.Outbound {
 	groupIndex := common.Index(outbounds, func(it boxOption.Outbound) bool {
 		return it.Tag == groupTag
@@ -0,0 +1,183 @@
+package template
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/serenity/common/cachefile"
+	M "github.com/sagernet/serenity/common/metadata"
+	"github.com/sagernet/serenity/common/semver"
+	"github.com/sagernet/serenity/option"
+	"github.com/sagernet/serenity/subscription"
+	"github.com/sagernet/sing-box"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	boxOption "github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+type warnLogger struct {
+	logger.ContextLogger
+	warnings []string
+}
+
+func (l *warnLogger) Warn(args ...any) {
+	l.warnings = append(l.warnings, F.ToString(args...))
+}
+
+func TestRenderSubscriptionTags(t *testing.T) {
+	t.Parallel()
+	ctx := box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+	cacheFile := cachefile.New(ctx, filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, cacheFile.Start())
+	defer cacheFile.Close()
+	manager, err := subscription.NewSubscriptionManager(ctx, logger.NOP(), cacheFile, "", nil, []option.Subscription{
+		{
+			Name:    "a",
+			Content: "ss://YWVzLTEyOC1nY206cGFzcw@a.example.com:8388#HK\n",
+		},
+		{
+			Name:    "b",
+			Content: "ss://YWVzLTEyOC1nY206cGFzcw@b.example.com:8388#JP\n",
+		},
+		{
+			Name:    "c",
+			Content: "ss://YWVzLTEyOC1nY206cGFzcw@c.example.com:8388#HK\n",
+		},
+		{
+			Name:    "all",
+			Include: []string{"a", "b"},
+			Process: []option.OutboundProcessOptions{{
+				Patch: []byte(`{"tcp_fast_open":true}`),
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	defer manager.Close()
+	subscriptions := func(names ...string) []*subscription.Subscription {
+		return common.Filter(manager.Subscriptions(), func(it *subscription.Subscription) bool {
+			return common.Contains(names, it.Name)
+		})
+	}
+	serverTags := func(options *boxOption.Options) []string {
+		return common.Map(common.Filter(options.Outbounds, func(it boxOption.Outbound) bool {
+			return it.Type == C.TypeShadowsocks
+		}), func(it boxOption.Outbound) string {
+			return it.Tag
+		})
+	}
+	requirePatched := func(options *boxOption.Options) {
+		for _, server := range options.Outbounds {
+			if server.Type == C.TypeShadowsocks {
+				require.True(t, server.Options.(*boxOption.ShadowsocksOutboundOptions).TCPFastOpen, server.Tag)
+			}
+		}
+	}
+
+	testLogger := &warnLogger{ContextLogger: logger.NOP()}
+	options, err := Default.Render(ctx, testLogger, M.Metadata{}, "test", nil, subscriptions("a", "all"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"HK", "JP"}, serverTags(options))
+	require.Empty(t, testLogger.warnings)
+	// the composite's patched copy is kept for the tag shared with its member, whatever the order in the profile
+	requirePatched(options)
+	options, err = Default.Render(ctx, testLogger, M.Metadata{}, "test", nil, []*subscription.Subscription{subscriptions("all")[0], subscriptions("a")[0]})
+	require.NoError(t, err)
+	require.Equal(t, []string{"HK", "JP"}, serverTags(options))
+	requirePatched(options)
+	require.Empty(t, testLogger.warnings)
+
+	testLogger = &warnLogger{ContextLogger: logger.NOP()}
+	options, err = Default.Render(ctx, testLogger, M.Metadata{}, "test", nil, subscriptions("a", "c"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"HK", "HK"}, serverTags(options))
+	require.Equal(t, []string{"duplicate outbound tag HK in subscriptions a and c"}, testLogger.warnings)
+}
+
+func TestRenderLegacyWireGuard(t *testing.T) {
+	t.Parallel()
+	ctx := box.Context(context.Background(), include.InboundRegistry(), include.OutboundRegistry(), include.EndpointRegistry())
+	cacheFile := cachefile.New(ctx, filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, cacheFile.Start())
+	defer cacheFile.Close()
+	manager, err := subscription.NewSubscriptionManager(ctx, logger.NOP(), cacheFile, "", nil, []option.Subscription{
+		{
+			Name: "wireguard",
+			Content: `
+[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.0.0.2/32
+
+[Peer]
+# Name = WARP
+PublicKey = bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=
+Endpoint = engage.cloudflareclient.com:2408
+Reserved = 1, 2, 3
+
+[Peer]
+# Name = LAN
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+AllowedIPs = 192.168.1.0/24
+Endpoint = 203.0.113.1:51820
+`,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	defer manager.Close()
+
+	options, err := Default.Render(ctx, logger.NOP(), M.Metadata{}, "test", nil, manager.Subscriptions())
+	require.NoError(t, err)
+	require.Len(t, options.Endpoints, 2)
+	require.False(t, common.Any(options.Outbounds, func(it boxOption.Outbound) bool {
+		return it.Type == C.TypeWireGuard
+	}))
+
+	version := semver.ParseVersion("1.10.0")
+	options, err = Default.Render(ctx, logger.NOP(), M.Metadata{Version: &version}, "test", nil, manager.Subscriptions())
+	require.NoError(t, err)
+	require.Empty(t, options.Endpoints)
+	wireGuardOutbounds := common.Filter(options.Outbounds, func(it boxOption.Outbound) bool {
+		return it.Type == C.TypeWireGuard
+	})
+	localAddress := []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")}
+	require.Equal(t, []boxOption.Outbound{
+		{
+			Type: C.TypeWireGuard,
+			Tag:  "WARP",
+			Options: &boxOption.LegacyWireGuardOutboundOptions{
+				LocalAddress: localAddress,
+				PrivateKey:   "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+				ServerOptions: boxOption.ServerOptions{
+					Server:     "engage.cloudflareclient.com",
+					ServerPort: 2408,
+				},
+				PeerPublicKey: "bmXOC+F1FxEMF9dyiK2H5/1SUtzH0JuVo51h2wPfgyo=",
+				Reserved:      []uint8{1, 2, 3},
+			},
+		},
+		{
+			Type: C.TypeWireGuard,
+			Tag:  "LAN",
+			Options: &boxOption.LegacyWireGuardOutboundOptions{
+				LocalAddress: localAddress,
+				PrivateKey:   "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=",
+				Peers: []boxOption.LegacyWireGuardPeer{{
+					ServerOptions: boxOption.ServerOptions{
+						Server:     "203.0.113.1",
+						ServerPort: 51820,
+					},
+					PublicKey:  "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+					AllowedIPs: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")},
+				}},
+			},
+		},
+	}, wireGuardOutbounds)
+}
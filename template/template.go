@@ -10,6 +10,7 @@ import (
 	"github.com/sagernet/serenity/template/filter"
 	boxOption "github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
 )
 
 const (
@@ -40,7 +41,7 @@ type ExtraGroup struct {
 	exclude []*regexp.Regexp
 }
 
-func (t *Template) Render(ctx context.Context, metadata M.Metadata, profileName string, outbounds [][]boxOption.Outbound, subscriptions []*subscription.Subscription) (*boxOption.Options, error) {
+func (t *Template) Render(ctx context.Context, logger logger.Logger, metadata M.Metadata, profileName string, outbounds [][]boxOption.Outbound, subscriptions []*subscription.Subscription) (*boxOption.Options, error) {
 	var options boxOption.Options
 	options.Log = t.Log
 	err := t.renderDNS(metadata, &options)
@@ -55,7 +56,7 @@ func (t *Template) Render(ctx context.Context, metadata M.Metadata, profileName
 	if err != nil {
 		return nil, E.Cause(err, "render inbounds")
 	}
-	err = t.renderOutbounds(metadata, &options, outbounds, subscriptions)
+	err = t.renderOutbounds(logger, metadata, &options, outbounds, subscriptions)
 	if err != nil {
 		return nil, E.Cause(err, "render outbounds")
 	}